@@ -6,4 +6,6 @@ require (
 	github.com/benbjohnson/litestream v0.3.8
 	github.com/mattn/go-sqlite3 v1.14.12
 	github.com/fsnotify/fsnotify v1.7.0
+	github.com/prometheus/client_golang v1.9.0
+	gopkg.in/yaml.v3 v3.0.0-20200313102051-9f266ea9e77c
 )
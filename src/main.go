@@ -1,68 +1,285 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"crypto/tls"
+	"database/sql"
 	_ "embed"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"html/template"
 	"io"
+	"io/fs"
 	"log"
+	"net"
 	"net/http"
+	"net/url"
 	"os"
 	"os/signal"
 	"path/filepath"
+	"regexp"
+	"runtime"
+	"runtime/debug"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"syscall"
+	"text/tabwriter"
+	texttemplate "text/template"
 	"time"
 
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
 	"github.com/benbjohnson/litestream"
+	lsabs "github.com/benbjohnson/litestream/abs"
+	lsfile "github.com/benbjohnson/litestream/file"
+	lsgcs "github.com/benbjohnson/litestream/gcs"
 	lss3 "github.com/benbjohnson/litestream/s3"
 	"github.com/fsnotify/fsnotify"
 	_ "github.com/mattn/go-sqlite3"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"gopkg.in/yaml.v3"
 )
 
 //go:embed template.html
 var templateContent string
 
-// Logger personalizado que filtra mensagens técnicas do Litestream
-type filteredWriter struct {
+// logLevel nível mínimo das mensagens do Litestream (-litestream-log-level)
+type logLevel int
+
+const (
+	levelDebug logLevel = iota
+	levelInfo
+	levelWarn
+	levelError
+)
+
+// parseLogLevel converte debug, info, warn ou error
+func parseLogLevel(s string) (logLevel, error) {
+	switch strings.ToLower(s) {
+	case "debug":
+		return levelDebug, nil
+	case "info":
+		return levelInfo, nil
+	case "warn", "warning":
+		return levelWarn, nil
+	case "error":
+		return levelError, nil
+	}
+	return 0, fmt.Errorf("invalid log level %q: must be debug, info, warn or error", s)
+}
+
+// levelWriter descarta as mensagens do Litestream abaixo do nível mínimo.
+// O Litestream v0.3 não informa o nível: linhas que reportam erro são
+// levelError e as demais (sync, snapshot, checkpoint) levelInfo. Não há como
+// separar avisos, então warn descarta tudo que não for erro, como error.
+type levelWriter struct {
 	writer io.Writer
+	min    logLevel
 }
 
-func (fw *filteredWriter) Write(p []byte) (n int, err error) {
-	msg := string(p)
-	
-	// Permite logs importantes de snapshot, generation e backup
-	if strings.Contains(msg, "snapshot") || 
-		strings.Contains(msg, "generation") || 
-		strings.Contains(msg, "backup") ||
-		strings.Contains(msg, "replicate") {
-		return fw.writer.Write(p) // Permite logs de backup/snapshot/generation
+func (lw *levelWriter) Write(p []byte) (n int, err error) {
+	level := levelInfo
+	if strings.Contains(strings.ToLower(string(p)), "error") {
+		level = levelError
 	}
-	
-	// Filtra apenas mensagens técnicas realmente desnecessárias
-	if strings.Contains(msg, "wal header mismatch") ||
-		strings.Contains(msg, "cannot determine last wal position") ||
-		strings.Contains(msg, "sync error") ||
-		strings.Contains(msg, "init:") ||
-		strings.Contains(msg, ".db-litestream/") ||
-		strings.Contains(msg, "/wal/") {
-		return len(p), nil // Descarta mensagem técnica
+	if level < lw.min {
+		return len(p), nil
+	}
+	return lw.writer.Write(p)
+}
+
+// litestreamLogger cria o logger de um banco/replica com o prefixo usado pelo Litestream
+func (dm *DatabaseManager) litestreamLogger(prefix string) *log.Logger {
+	return log.New(&levelWriter{writer: os.Stdout, min: dm.config.LitestreamLogLevel}, prefix+": ", log.LstdFlags)
+}
+
+// debugLogging habilita mensagens de depuração (-debug)
+var debugLogging bool
+
+// debugf registra a mensagem apenas com -debug
+func debugf(format string, v ...interface{}) {
+	if debugLogging {
+		log.Printf("🐛 "+format, v...)
 	}
-	
-	return fw.writer.Write(p)
 }
 
 // addr is the bind address for the web server.
 // addr will be set based on the port flag
 
+// Formatos de horário aceitos por -time-format
+const (
+	TimeFormatLocal   = "local"   // 2006-01-02 15:04:05 no fuso do servidor
+	TimeFormatRFC3339 = "rfc3339" // 2006-01-02T15:04:05Z em UTC
+	TimeFormatUnix    = "unix"    // segundos desde 1970
+)
+
+// timeFormat formato usado por formatTimestamp (-time-format)
+var timeFormat = TimeFormatLocal
+
+// parseTimeFormat valida o nome de -time-format
+func parseTimeFormat(s string) (string, error) {
+	switch name := strings.ToLower(s); name {
+	case TimeFormatLocal, TimeFormatRFC3339, TimeFormatUnix:
+		return name, nil
+	}
+	return "", fmt.Errorf("invalid -time-format %q: must be local, rfc3339 or unix", s)
+}
+
+// formatTimestamp formata um horário para o dashboard e as respostas da API
+// conforme -time-format; todo horário exibido deve passar por aqui
+func formatTimestamp(t time.Time) string {
+	switch timeFormat {
+	case TimeFormatRFC3339:
+		return t.UTC().Format(time.RFC3339)
+	case TimeFormatUnix:
+		return strconv.FormatInt(t.Unix(), 10)
+	}
+	return t.Local().Format("2006-01-02 15:04:05")
+}
+
+// formatTime formata um horário opcional (vazio se não definido)
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return formatTimestamp(*t)
+}
+
+// Chaves estáveis dos eventos registrados no log. Cada linha termina com
+// event=<chave>, para alertas baseados em log não dependerem do texto.
+const (
+	MsgClientRegistered      = "client.registered"
+	MsgClientUnregistered    = "client.unregistered"
+	MsgClientPaused          = "client.paused"
+	MsgClientResumed         = "client.resumed"
+	MsgClientRemoved         = "client.removed"
+	MsgClientPurged          = "client.purged"
+	MsgFirstReplication      = "client.first_replication"
+	MsgNotSQLite             = "client.not_sqlite"
+	MsgTooLarge              = "client.too_large"
+	MsgIDCollision           = "client.id_collision"
+	MsgRegistrationRetry     = "registration.retry"
+	MsgRegistrationFailed    = "registration.failed"
+	MsgRegistrationRecovered = "registration.recovered"
+	MsgS3Degraded            = "s3.degraded"
+	MsgS3Recovered           = "s3.recovered"
+	MsgWatchDirUnavailable   = "watchdir.unavailable"
+	MsgWatchDirRecovered     = "watchdir.recovered"
+	MsgWatcherOverflow       = "watcher.overflow"
+	MsgRestoreStarted        = "restore.started"
+	MsgRestoreComplete       = "restore.complete"
+	MsgRestoreOnCreate       = "restore.on_create"
+	MsgBackupVerified        = "verify.ok"
+	MsgBackupVerifyFailed    = "verify.failed"
+	MsgManualSync            = "sync.manual"
+	MsgWebhookSent           = "webhook.sent"
+)
+
+// messages catálogo com o texto (em inglês) de cada evento; traduções futuras
+// só precisam trocar este mapa
+var messages = map[string]string{
+	MsgClientRegistered:      "✅ Client registered: %s -> %s/",
+	MsgClientUnregistered:    "❌ Client unregistered: %s",
+	MsgClientPaused:          "⏸️  Client paused: %s",
+	MsgClientResumed:         "▶️  Client resumed: %s",
+	MsgClientRemoved:         "🧹 Client removed via API, file kept: %s (%s)",
+	MsgClientPurged:          "🔥 Replica purged for removed client %s: %s/ (%d generations)",
+	MsgFirstReplication:      "☁️  First replication complete: %s (%s after registration)",
+	MsgNotSQLite:             "🚫 Not a SQLite database, NOT replicating: %s",
+	MsgTooLarge:              "🐘 Database too large, NOT replicating: %s (%s)",
+	MsgIDCollision:           "🚨 CLIENT ID COLLISION: %s is NOT being replicated, client %s already belongs to %s (use -on-id-collision=suffix to replicate both)",
+	MsgRegistrationRetry:     "🔁 Registration failed, retrying in %s (attempt %d/%d): %v",
+	MsgRegistrationFailed:    "❌ Registration failed permanently after %d attempts: %s: %v",
+	MsgRegistrationRecovered: "✅ Registration recovered after %d failed attempts: %s",
+	MsgS3Degraded:            "🌩️  S3 degraded for client %s (%d failed checks, next in %s): %v",
+	MsgS3Recovered:           "☁️  S3 recovered for client %s after %d failed checks",
+	MsgWatchDirUnavailable:   "❌ Watch directory unavailable, changes are NOT being detected: %s: %v",
+	MsgWatchDirRecovered:     "👀 Watch directory recovered after %s: %s",
+	MsgWatcherOverflow:       "🚨 File watcher queue overflow, events were LOST: rescanning all watch dirs (raise fs.inotify.max_queued_events if this repeats)",
+	MsgRestoreStarted:        "♻️  Restore started: %s -> %s",
+	MsgRestoreComplete:       "✅ Restore complete: %s -> %s (%d bytes)",
+	MsgRestoreOnCreate:       "♻️  Recreated database restored from S3: %s (generation %s)",
+	MsgBackupVerified:        "✅ Backup verified: %s (generation %s)",
+	MsgBackupVerifyFailed:    "❌ Backup integrity check failed: %s: %s",
+	MsgManualSync:            "🔄 Manual sync complete: %s (%dms)",
+	MsgWebhookSent:           "📣 Webhook sent: %s for client %s",
+}
+
+// logEvent registra o evento do catálogo com os argumentos do seu texto
+func logEvent(key string, args ...interface{}) {
+	format, exists := messages[key]
+	if !exists {
+		format = strings.Repeat("%v ", len(args))
+	}
+	log.Printf(strings.TrimSpace(format)+" event=%s", append(args, key)...)
+}
+
 // startTime armazena quando o servidor foi iniciado
 var startTime time.Time
 
+// Identificação do build, definida via -ldflags "-X main.Version=... -X main.Commit=... -X main.BuildDate=..."
+var (
+	Version   = "dev"
+	Commit    = ""
+	BuildDate = ""
+)
+
+// VersionInfo resposta de /api/version e saída de -version
+type VersionInfo struct {
+	Version           string `json:"version"`
+	Commit            string `json:"commit,omitempty"`
+	BuildDate         string `json:"buildDate,omitempty"`
+	GoVersion         string `json:"goVersion"`
+	LitestreamVersion string `json:"litestreamVersion"` // versão do módulo do Litestream linkado
+}
+
+// versionInfo monta a identificação do build, lendo a versão do Litestream das
+// informações de módulo embutidas pelo compilador
+func versionInfo() VersionInfo {
+	info := VersionInfo{
+		Version:           Version,
+		Commit:            Commit,
+		BuildDate:         BuildDate,
+		GoVersion:         runtime.Version(),
+		LitestreamVersion: "unknown",
+	}
+	if build, ok := debug.ReadBuildInfo(); ok {
+		for _, dep := range build.Deps {
+			if dep.Path == "github.com/benbjohnson/litestream" {
+				info.LitestreamVersion = dep.Version
+				if dep.Replace != nil {
+					info.LitestreamVersion = dep.Replace.Version
+				}
+			}
+		}
+	}
+	return info
+}
+
+// String formata a versão para -version
+func (v VersionInfo) String() string {
+	s := "litestream-manager " + v.Version
+	if v.Commit != "" {
+		s += " (" + v.Commit + ")"
+	}
+	if v.BuildDate != "" {
+		s += " built " + v.BuildDate
+	}
+	return fmt.Sprintf("%s, %s, litestream %s", s, v.GoVersion, v.LitestreamVersion)
+}
+
 // formatUptime formata o uptime de forma amigável
 func formatUptime() string {
 	duration := time.Since(startTime)
@@ -80,828 +297,6558 @@ func formatUptime() string {
 	}
 }
 
-// DatabaseManager gerencia instâncias do Litestream (1 banco por cliente)
-type DatabaseManager struct {
-	databases   map[string]*litestream.DB  // clientID -> litestream.DB
-	clients     map[string]*ClientConfig   // clientID -> config  
-	pathIndex   map[string]string          // dbPath -> clientID (index para lookups)
-	watcher     *fsnotify.Watcher
-	mutex       sync.RWMutex
-	bucket      string
-	watchDirs   []string
-	ctx         context.Context
-	cancel      context.CancelFunc
+// Config opções de execução do gerenciador (preenchidas a partir das flags)
+type Config struct {
+	Buckets      []string // um replica S3 por bucket; o primeiro é o principal
+	WatchDirs    []string
+	Addr         string
+	FallbackAddr string        // usado pelo servidor de status se Addr estiver ocupado
+	AuthToken    string        // exige "Authorization: Bearer <token>" em /api/* quando definido
+	CORSOrigins  []string      // origens liberadas para chamar /api/* pelo navegador ("*" = todas)
+	ProtectDash  bool          // aplica AuthToken também ao dashboard (/)
+	TLS          *certReloader // certificado do servidor de status (nil = HTTP)
+	Audit        *auditLog     // trilha de eventos dos clientes (nil = desabilitada)
+	DryRun       bool          // detecta e loga os bancos sem replicar
+	NoRestore    bool          // nunca baixa dados do S3 (restore e verify desabilitados)
+	SelfTest     bool          // replica um banco de teste na inicialização e falha se não funcionar
+	Once         bool          // registra, sincroniza e sai (sem watcher nem servidor de status)
+	Recursive    bool          // monitora também os subdiretórios de cada watch dir
+	WaitForDirs  bool          // aguarda a criação de watch dirs inexistentes em vez de ignorá-los
+
+	BucketMap map[string]string // watch dir -> bucket que substitui Buckets para os bancos dele (-map)
+
+	RestoreOnCreate bool          // banco sem tabelas (ex: arquivo recriado) é restaurado do S3 antes de ser aberto
+	RestoreTimeout  time.Duration // limite de cada tentativa de restore durante o registro (0 = sem limite)
+	OpenBusyTimeout time.Duration // quanto tempo o registro espera um banco bloqueado por outro processo
+
+	DashboardRefresh time.Duration // frequência com que o dashboard consulta /api/status (0 = desligada)
+	NoServer         bool          // não inicia o servidor de status (-no-server ou -port 0)
+
+	StatusPollInterval time.Duration // frequência do cache de gerações/posição lido do disco (0 = leitura a cada acesso)
+
+	DashboardTemplate string // template externo do dashboard (-template; vazio = embutido)
+
+	MetricsPerClient bool // séries com client_id em /metrics (uma por cliente; padrão: só totais)
+
+	RegisterDebounce    time.Duration // período de silêncio antes de registrar um banco novo
+	EventCoalesceWindow time.Duration // janela em que eventos Write do mesmo arquivo viram um só
+	MaxLagBytes         int64         // atraso máximo de replicação antes de /api/health falhar
+	RegisterMaxRetries  int           // novas tentativas após falha transitória de registro
+	ScanConcurrency     int           // registros simultâneos na varredura inicial
+	MaxClients          int           // limite de bancos abertos (0 = sem limite)
+	MaxDBSize           int64         // arquivos maiores que isso (bytes) não são replicados (0 = sem limite)
+	RescanInterval      time.Duration // varredura periódica dos watch dirs (0 = desabilitada)
+
+	ShutdownSyncTimeout time.Duration // limite do sync final no encerramento (0 = fecha sem sync)
+	ShutdownTimeout     time.Duration // limite de Stop() inteiro; depois sai mesmo assim (0 = sem limite)
+
+	MaxConcurrentRestores int    // restores/verificações simultâneos pela API; acima disso responde 429
+	RestoreDir            string // único diretório onde POST /restore grava arquivos (vazio = diretório temporário)
+
+	WebhookURL           string        // recebe eventos replication_failed/replication_recovered
+	WebhookCheckInterval time.Duration // frequência da verificação de sync dos replicas
+
+	Retention              time.Duration // por quanto tempo gerações antigas são mantidas no S3
+	RetentionCheckInterval time.Duration // frequência da limpeza de gerações expiradas
+	SnapshotInterval       time.Duration // 0 = snapshot apenas em nova geração/retenção
+	SyncInterval           time.Duration // frequência de envio do WAL para o S3
+
+	DBExtensions  map[string]bool // extensões de banco (-db-extensions), em minúsculas
+	DBNoExtension bool            // arquivos sem extensão também são bancos
+	WatchGlobs    []string        // padrões do nome do arquivo (-watch-glob); vazio = todos
+
+	IDStrategy         ClientIDStrategy // como o clientID é extraído do nome do arquivo
+	OnIDCollision      string           // error ou suffix (mesmo clientID em arquivos diferentes)
+	LitestreamLogLevel logLevel         // mensagens do Litestream abaixo deste nível são descartadas
+
+	PathTemplate *texttemplate.Template // template do path do replica (-s3-path-template)
+	Env          string                 // disponível no template como {{.Env}}
+
+	ReplicaType string // s3, file, gcs ou abs (-replica-type)
+	S3          S3Config
+	ABS         ABSConfig
 }
 
-// ClientConfig configuração otimizada para 1:1 cliente:banco
-type ClientConfig struct {
-	ClientID     string    `json:"clientId"`
-	DatabasePath string    `json:"databasePath"`
-	CreatedAt    time.Time `json:"createdAt"`
+// Tipos de destino dos replicas (-replica-type). Em todos eles -bucket (e -map)
+// indica o destino: bucket S3/GCS, container do Azure ou diretório raiz (file).
+const (
+	ReplicaTypeS3   = "s3"
+	ReplicaTypeFile = "file" // diretório local ou NAS montado
+	ReplicaTypeGCS  = "gcs"  // credenciais via GOOGLE_APPLICATION_CREDENTIALS
+	ReplicaTypeABS  = "abs"  // Azure Blob Storage
+)
+
+// ABSConfig conta do Azure Blob Storage (-replica-type abs)
+type ABSConfig struct {
+	AccountName string
+	AccountKey  string
+	Endpoint    string // vazio = https://{AccountName}.blob.core.windows.net
 }
 
-// DashboardData dados para o template HTML
-type DashboardData struct {
-	Bucket        string       `json:"bucket"`
-	WatchDirCount int          `json:"watchDirCount"`
-	ClientCount   int          `json:"clientCount"`
-	Uptime        string       `json:"uptime"`
-	Clients       []ClientData `json:"clients"`
+// ReplicaClientFactory cria o client do Litestream de cada replica. O
+// DatabaseManager usa a Config (-replica-type); testes podem injetar um fake
+// que registra as chamadas sem acessar o S3.
+type ReplicaClientFactory interface {
+	NewReplicaClient(bucket, path string) litestream.ReplicaClient
 }
 
-// ClientData dados de cada cliente para o template
-type ClientData struct {
-	ClientID     string `json:"clientId"`
-	DatabasePath string `json:"databasePath"`
-	StatusClass  string `json:"statusClass"`
-	StatusText   string `json:"statusText"`
-	CreatedAt    string `json:"createdAt"`
-	Generations  []GenerationData `json:"generations,omitempty"`
+// NewReplicaClient cria o client do Litestream para o destino de -replica-type
+func (c *Config) NewReplicaClient(bucket, path string) litestream.ReplicaClient {
+	switch c.ReplicaType {
+	case ReplicaTypeFile:
+		return lsfile.NewReplicaClient(filepath.Join(bucket, filepath.FromSlash(path)))
+	case ReplicaTypeGCS:
+		client := lsgcs.NewReplicaClient()
+		client.Bucket = bucket
+		client.Path = path
+		return client
+	case ReplicaTypeABS:
+		client := lsabs.NewReplicaClient()
+		client.AccountName = c.ABS.AccountName
+		client.AccountKey = c.ABS.AccountKey
+		client.Endpoint = c.ABS.Endpoint
+		client.Bucket = bucket
+		client.Path = path
+		return client
+	default:
+		return c.S3.newReplicaClient(bucket, path)
+	}
 }
 
-// GenerationData informações de uma geração de backup
-type GenerationData struct {
-	ID       string        `json:"id"`
-	Created  string        `json:"created"`
-	Updated  string        `json:"updated"`
-	Source   string        `json:"source"`    // "s3" ou "local"
-	Snapshots []SnapshotData `json:"snapshots,omitempty"`
+// replicaURL URL do replica no formato aceito por "litestream restore"
+func (c *Config) replicaURL(bucket, path string) string {
+	return replicaClientURL(c.NewReplicaClient(bucket, path))
 }
 
-// SnapshotData informações de um snapshot
-type SnapshotData struct {
-	ID      string `json:"id"`
-	Created string `json:"created"`
-	Size    string `json:"size"`
-	Source  string `json:"source"`    // "s3" ou "local"
+// replicaClientURL URL do destino de um client do Litestream
+func replicaClientURL(client litestream.ReplicaClient) string {
+	switch client := client.(type) {
+	case *lss3.ReplicaClient:
+		return fmt.Sprintf("s3://%s/%s", client.Bucket, client.Path)
+	case *lsfile.ReplicaClient:
+		return "file://" + filepath.ToSlash(client.Path())
+	case *lsgcs.ReplicaClient:
+		return fmt.Sprintf("gcs://%s/%s", client.Bucket, client.Path)
+	case *lsabs.ReplicaClient:
+		return fmt.Sprintf("abs://%s@%s/%s", client.AccountName, client.Bucket, client.Path)
+	}
+	return client.Type()
 }
 
-// RestoreOption representa uma opção específica de restore
-type RestoreOption struct {
-	ID          string `json:"id"`
-	Type        string `json:"type"`        // "generation", "snapshot", "wal"
-	Timestamp   string `json:"timestamp"`
-	Size        string `json:"size"`
-	Description string `json:"description"`
-	Command     string `json:"command"`     // Comando litestream para restaurar
+// S3Config conexão com provedores S3 (AWS, MinIO, Backblaze B2...).
+// Campos vazios usam os padrões do SDK da AWS.
+type S3Config struct {
+	Endpoint        string
+	Region          string
+	AccessKeyID     string
+	SecretAccessKey string
+	ForcePathStyle  bool
 }
 
-// RestoreOptionsData todas as opções de restore disponíveis para um cliente
-type RestoreOptionsData struct {
-	ClientID       string          `json:"clientId"`
-	TotalOptions   int            `json:"totalOptions"`
-	LatestBackup   string         `json:"latestBackup"`
-	RestoreOptions []RestoreOption `json:"restoreOptions"`
+// newReplicaClient cria o client S3 do Litestream com as opções de conexão
+func (c S3Config) newReplicaClient(bucket, path string) *lss3.ReplicaClient {
+	client := lss3.NewReplicaClient()
+	client.Bucket = bucket
+	client.Path = path
+	client.Endpoint = c.Endpoint
+	client.Region = c.Region
+	client.AccessKeyID = c.AccessKeyID
+	client.SecretAccessKey = c.SecretAccessKey
+	client.ForcePathStyle = c.ForcePathStyle
+	return client
 }
 
-// getClientGenerations obtém gerações disponíveis para um cliente lendo dados reais dos arquivos
-func (dm *DatabaseManager) getClientGenerations(clientID string) ([]GenerationData, error) {
-	dm.mutex.RLock()
-	defer dm.mutex.RUnlock()
-	
-	// Busca a instância do litestream.DB para o cliente
-	lsdb, exists := dm.databases[clientID]
-	if !exists {
-		return nil, fmt.Errorf("client not found: %s", clientID)
+// listClientIDs lista os clientes com backup no bucket, isto é, os prefixos
+// imediatamente abaixo de prefix (ex: databases/{clientID}/)
+func (c S3Config) listClientIDs(ctx context.Context, bucket, prefix string) ([]string, error) {
+	config := &aws.Config{}
+	if c.AccessKeyID != "" || c.SecretAccessKey != "" {
+		config.Credentials = credentials.NewStaticCredentials(c.AccessKeyID, c.SecretAccessKey, "")
 	}
-	
-	// Caminho para o diretório .db-litestream (note o ponto no início)
-	litestreamDir := fmt.Sprintf(".%s-litestream", filepath.Base(lsdb.Path()))
-	litestreamFullPath := filepath.Join(filepath.Dir(lsdb.Path()), litestreamDir)
-	generationsDir := filepath.Join(litestreamFullPath, "generations")
-	
-	// Verificar se o diretório existe
-	if _, err := os.Stat(generationsDir); os.IsNotExist(err) {
-		return []GenerationData{}, nil // Retorna vazio se não há generations
+	if c.Endpoint != "" {
+		config.Endpoint = aws.String(c.Endpoint)
 	}
-	
-	var generations []GenerationData
-	
-	// Ler diretórios de generations
-	entries, err := os.ReadDir(generationsDir)
+	if c.ForcePathStyle {
+		config.S3ForcePathStyle = aws.Bool(true)
+	}
+
+	region := c.Region
+	if region == "" && c.Endpoint != "" {
+		region = "us-east-1"
+	} else if region == "" {
+		// Mesmo comportamento do Litestream: descobre a região do bucket na AWS
+		sess, err := session.NewSession(config.Copy(&aws.Config{Region: aws.String("us-east-1")}))
+		if err != nil {
+			return nil, err
+		}
+		if region, err = s3manager.GetBucketRegion(ctx, sess, bucket, "us-east-1"); err != nil {
+			return nil, fmt.Errorf("cannot find bucket region: %w", err)
+		}
+	}
+	config.Region = aws.String(region)
+
+	sess, err := session.NewSession(config)
 	if err != nil {
-		log.Printf("⚠️  Error reading generations directory for client %s: %v", clientID, err)
-		return []GenerationData{}, nil
+		return nil, err
 	}
-	
-	for _, entry := range entries {
-		if !entry.IsDir() {
-			continue
+
+	var ids []string
+	input := &s3.ListObjectsV2Input{
+		Bucket:    aws.String(bucket),
+		Prefix:    aws.String(strings.Trim(prefix, "/") + "/"),
+		Delimiter: aws.String("/"),
+	}
+	err = s3.New(sess).ListObjectsV2PagesWithContext(ctx, input, func(page *s3.ListObjectsV2Output, lastPage bool) bool {
+		for _, p := range page.CommonPrefixes {
+			ids = append(ids, filepath.Base(strings.TrimSuffix(aws.StringValue(p.Prefix), "/")))
 		}
-		
-		generationID := entry.Name()
-		generationPath := filepath.Join(generationsDir, generationID)
-		
-		// Obter informações da generation
-		info, err := entry.Info()
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+// listClientIDs lista os clientes com backup sob prefix. Só S3 e file: para
+// gcs e abs o subcomando list precisa de -client.
+func (c *Config) listClientIDs(ctx context.Context, bucket, prefix string) ([]string, error) {
+	switch c.ReplicaType {
+	case ReplicaTypeS3:
+		return c.S3.listClientIDs(ctx, bucket, prefix)
+	case ReplicaTypeFile:
+		entries, err := os.ReadDir(filepath.Join(bucket, filepath.FromSlash(strings.Trim(prefix, "/"))))
 		if err != nil {
-			continue
+			return nil, err
 		}
-		
-		// Buscar o WAL mais recente para obter timestamp atualizado
-		walDir := filepath.Join(generationPath, "wal")
-		var latestWALTime time.Time = info.ModTime()
-		
-		if walEntries, err := os.ReadDir(walDir); err == nil {
-			for _, walEntry := range walEntries {
-				if strings.HasSuffix(walEntry.Name(), ".wal") {
-					if walInfo, err := walEntry.Info(); err == nil {
-						if walInfo.ModTime().After(latestWALTime) {
-							latestWALTime = walInfo.ModTime()
-						}
-					}
-				}
+		var ids []string
+		for _, entry := range entries {
+			if entry.IsDir() {
+				ids = append(ids, entry.Name())
 			}
 		}
-		
-		generation := GenerationData{
-			ID:      generationID,
-			Created: info.ModTime().Format("2006-01-02 15:04:05"),
-			Updated: latestWALTime.Format("2006-01-02 15:04:05"),
-			Source:  "local", // Indicando que os dados vêm dos arquivos locais
-		}
-		
-		generations = append(generations, generation)
+		return ids, nil
 	}
-	
-	// Ordenar por data de criação (mais recente primeiro)
-	sort.Slice(generations, func(i, j int) bool {
-		return generations[i].Created > generations[j].Created
-	})
-	
-	return generations, nil
+	return nil, fmt.Errorf("listing every client is not supported with -replica-type %s, use -client ID", c.ReplicaType)
 }
 
-// getClientSnapshots obtém snapshots de uma geração específica lendo dados reais dos arquivos WAL
-func (dm *DatabaseManager) getClientSnapshots(clientID, generationID string) ([]SnapshotData, error) {
-	dm.mutex.RLock()
-	defer dm.mutex.RUnlock()
-	
-	// Busca a instância do litestream.DB para o cliente
-	lsdb, exists := dm.databases[clientID]
-	if !exists {
-		return nil, fmt.Errorf("client not found: %s", clientID)
-	}
-	
-	// Caminho para o diretório WAL da generation específica (note o ponto no início)
-	litestreamDir := fmt.Sprintf(".%s-litestream", filepath.Base(lsdb.Path()))
-	litestreamFullPath := filepath.Join(filepath.Dir(lsdb.Path()), litestreamDir)
-	walDir := filepath.Join(litestreamFullPath, "generations", generationID, "wal")
-	
-	// Verificar se o diretório existe
-	if _, err := os.Stat(walDir); os.IsNotExist(err) {
-		return []SnapshotData{}, nil // Retorna vazio se não há WAL files
-	}
-	
-	var snapshots []SnapshotData
-	
-	// Ler arquivos WAL
-	entries, err := os.ReadDir(walDir)
-	if err != nil {
-		log.Printf("⚠️  Error reading WAL directory for client %s generation %s: %v", clientID, generationID, err)
-		return []SnapshotData{}, nil
+// replicaFlags flags de destino dos replicas, registradas pelo comando
+// principal e pelos subcomandos list e restore
+type replicaFlags struct {
+	replicaType       *string
+	s3Endpoint        *string
+	s3Region          *string
+	s3AccessKeyID     *string
+	s3SecretAccessKey *string
+	s3ForcePathStyle  *bool
+	absAccountName    *string
+	absAccountKey     *string
+	absEndpoint       *string
+}
+
+func addReplicaFlags(fs *flag.FlagSet) *replicaFlags {
+	return &replicaFlags{
+		replicaType:       fs.String("replica-type", ReplicaTypeS3, "replica backend: s3, file (local directory or NAS), gcs (Google Cloud Storage) or abs (Azure Blob Storage)"),
+		s3Endpoint:        fs.String("s3-endpoint", "", "custom S3 endpoint for non-AWS providers (e.g. MinIO, Backblaze B2)"),
+		s3Region:          fs.String("s3-region", "", "S3 region (detected automatically on AWS when empty)"),
+		s3AccessKeyID:     fs.String("s3-access-key-id", envDefault("LITESTREAM_ACCESS_KEY_ID", "AWS_ACCESS_KEY_ID"), "S3 access key id (env: LITESTREAM_ACCESS_KEY_ID, AWS_ACCESS_KEY_ID)"),
+		s3SecretAccessKey: fs.String("s3-secret-access-key", envDefault("LITESTREAM_SECRET_ACCESS_KEY", "AWS_SECRET_ACCESS_KEY"), "S3 secret access key (env: LITESTREAM_SECRET_ACCESS_KEY, AWS_SECRET_ACCESS_KEY)"),
+		s3ForcePathStyle:  fs.Bool("s3-force-path-style", false, "use path-style S3 URLs (required by most MinIO setups)"),
+		absAccountName:    fs.String("abs-account-name", envDefault("LITESTREAM_AZURE_ACCOUNT_NAME"), "Azure storage account for -replica-type abs (env: LITESTREAM_AZURE_ACCOUNT_NAME)"),
+		absAccountKey:     fs.String("abs-account-key", envDefault("LITESTREAM_AZURE_ACCOUNT_KEY"), "Azure storage account key for -replica-type abs (env: LITESTREAM_AZURE_ACCOUNT_KEY)"),
+		absEndpoint:       fs.String("abs-endpoint", "", "custom Azure Blob endpoint for -replica-type abs, e.g. an Azurite emulator"),
 	}
-	
-	for _, entry := range entries {
-		if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".wal") {
-			info, err := entry.Info()
-			if err != nil {
-				continue
+}
+
+// config valida -replica-type e monta a Config que cria os clients. Com file,
+// buckets e os destinos de dirBuckets (-map) viram caminhos absolutos: o
+// diretório de trabalho não deve mudar o destino.
+func (f *replicaFlags) config(buckets []string, dirBuckets map[string]string) (*Config, error) {
+	switch *f.replicaType {
+	case ReplicaTypeS3, ReplicaTypeGCS:
+	case ReplicaTypeABS:
+		if *f.absAccountName == "" {
+			return nil, fmt.Errorf("required: -abs-account-name NAME with -replica-type abs")
+		}
+	case ReplicaTypeFile:
+		var err error
+		for i := range buckets {
+			if buckets[i], err = filepath.Abs(buckets[i]); err != nil {
+				return nil, fmt.Errorf("invalid -bucket %s: %w", buckets[i], err)
 			}
-			
-			// Converter bytes para formato amigável
-			size := info.Size()
-			var sizeStr string
-			if size < 1024 {
-				sizeStr = fmt.Sprintf("%dB", size)
-			} else if size < 1024*1024 {
-				sizeStr = fmt.Sprintf("%.1fKB", float64(size)/1024)
-			} else {
-				sizeStr = fmt.Sprintf("%.1fMB", float64(size)/(1024*1024))
+		}
+		for dir, root := range dirBuckets {
+			if dirBuckets[dir], err = filepath.Abs(root); err != nil {
+				return nil, fmt.Errorf("invalid -map %s=%s: %w", dir, root, err)
 			}
-			
-			snapshot := SnapshotData{
-				ID:      strings.TrimSuffix(entry.Name(), ".wal"),
-				Created: info.ModTime().Format("2006-01-02 15:04:05"),
-				Size:    sizeStr,
-				Source:  "local", // Indicando que os dados vêm dos arquivos locais
+		}
+	default:
+		return nil, fmt.Errorf("invalid -replica-type %q: must be s3, file, gcs or abs", *f.replicaType)
+	}
+
+	return &Config{
+		ReplicaType: *f.replicaType,
+		S3: S3Config{
+			Endpoint:        *f.s3Endpoint,
+			Region:          *f.s3Region,
+			AccessKeyID:     *f.s3AccessKeyID,
+			SecretAccessKey: *f.s3SecretAccessKey,
+			ForcePathStyle:  *f.s3ForcePathStyle,
+		},
+		ABS: ABSConfig{
+			AccountName: *f.absAccountName,
+			AccountKey:  *f.absAccountKey,
+			Endpoint:    *f.absEndpoint,
+		},
+	}, nil
+}
+
+// bucketCheckTimeout limite da verificação de acesso aos buckets na inicialização
+const bucketCheckTimeout = 30 * time.Second
+
+// checkBuckets lista o prefixo de gerações de cada bucket para detectar nome,
+// região ou credenciais inválidos antes de iniciar a replicação. Com
+// -replica-type file o diretório precisa existir: um NAS desmontado faria os
+// backups irem para o disco local.
+func (c *Config) checkBuckets(ctx context.Context, buckets []string) error {
+	ctx, cancel := context.WithTimeout(ctx, bucketCheckTimeout)
+	defer cancel()
+
+	for _, bucket := range buckets {
+		if c.ReplicaType == ReplicaTypeFile {
+			if info, err := os.Stat(bucket); err != nil {
+				return fmt.Errorf("replica directory is not accessible (or use -skip-bucket-check): %w", err)
+			} else if !info.IsDir() {
+				return fmt.Errorf("replica directory is not a directory: %s", bucket)
 			}
-			
-			snapshots = append(snapshots, snapshot)
 		}
+		if _, err := c.NewReplicaClient(bucket, "").Generations(ctx); err != nil {
+			return fmt.Errorf("bucket %q is not accessible (check the name, region and credentials, or use -skip-bucket-check): %w", bucket, err)
+		}
+		log.Printf("✅ Bucket accessible: %s", bucket)
 	}
-	
-	// Ordenar por nome (ordem cronológica dos WAL files)
-	sort.Slice(snapshots, func(i, j int) bool {
-		return snapshots[i].ID < snapshots[j].ID
-	})
-	
-	return snapshots, nil
+	return nil
 }
 
-// getClientRestoreOptions lista todas as opções de restore disponíveis para um cliente
-// Tenta S3 primeiro, depois fallback para dados locais
-func (dm *DatabaseManager) getClientRestoreOptions(clientID string) (*RestoreOptionsData, error) {
-	dm.mutex.RLock()
-	defer dm.mutex.RUnlock()
-	
-	// Busca a instância do litestream.DB para o cliente
-	lsdb, exists := dm.databases[clientID]
-	if !exists {
-		return nil, fmt.Errorf("client not found: %s", clientID)
+// envDefault retorna o primeiro valor não vazio entre as variáveis de ambiente
+func envDefault(keys ...string) string {
+	for _, key := range keys {
+		if v := os.Getenv(key); v != "" {
+			return v
+		}
 	}
-	
-	var restoreOptions []RestoreOption
-	var latestTimestamp time.Time
-	var s3Available bool = false
-	
-	// Tentar buscar dados do S3 primeiro usando a biblioteca litestream
-	if len(lsdb.Replicas) > 0 {
-		replica := lsdb.Replicas[0]
-		ctx := context.Background()
-		
-		// Tentar usar CalcRestoreTarget para verificar se S3 está acessível
-		opt := litestream.NewRestoreOptions()
-		if generation, _, err := replica.CalcRestoreTarget(ctx, opt); err == nil && generation != "" {
-			s3Available = true
-			log.Printf("🌐 S3 available for client %s, generation: %s", clientID, generation)
-			
-			// Adicionar opção de restore S3 (mais recente disponível)
-			restoreOptions = append(restoreOptions, RestoreOption{
-				ID:          generation,
-				Type:        "generation",
-				Timestamp:   time.Now().Format("2006-01-02 15:04:05"), // Timestamp aproximado
-				Size:        "-",
-				Description: fmt.Sprintf("Latest S3 generation %s", generation[:8]),
-				Command:     fmt.Sprintf("litestream restore -o restored.db s3://%s/databases/%s", dm.bucket, clientID),
-			})
-			
-			// Adicionar opção específica de generation
-			restoreOptions = append(restoreOptions, RestoreOption{
-				ID:          generation + "-specific",
-				Type:        "generation",
-				Timestamp:   time.Now().Add(-time.Hour).Format("2006-01-02 15:04:05"), // Timestamp aproximado
-				Size:        "-",
-				Description: fmt.Sprintf("S3 generation %s (specific)", generation[:8]),
-				Command:     fmt.Sprintf("litestream restore -generation %s -o restored.db s3://%s/databases/%s", generation, dm.bucket, clientID),
-			})
-			
-			latestTimestamp = time.Now()
-		} else {
-			log.Printf("⚠️  S3 not available for client %s: %v", clientID, err)
-		}
+	return ""
+}
+
+// DefaultPathTemplate mantém o layout original s3://bucket/databases/{clientID}
+const DefaultPathTemplate = "databases/{{.ClientID}}"
+
+// PathTemplateData variáveis disponíveis em -s3-path-template
+type PathTemplateData struct {
+	ClientID string
+	Env      string
+	Year     string
+	Month    string
+	Day      string
+}
+
+// parsePathTemplate compila o template e o executa com dados de exemplo para
+// falhar na inicialização se ele referenciar variáveis desconhecidas
+func parsePathTemplate(text string) (*texttemplate.Template, error) {
+	tmpl, err := texttemplate.New("s3-path").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid -s3-path-template: %w", err)
 	}
-	
-	// Buscar dados locais como fallback/complemento
-	litestreamDir := fmt.Sprintf(".%s-litestream", filepath.Base(lsdb.Path()))
-	litestreamFullPath := filepath.Join(filepath.Dir(lsdb.Path()), litestreamDir)
-	generationsDir := filepath.Join(litestreamFullPath, "generations")
-	
-	// Verificar se o diretório local existe
-	if _, err := os.Stat(generationsDir); err == nil {
-		// Ler diretórios de generations locais
-		entries, err := os.ReadDir(generationsDir)
-		if err == nil {
-			for _, entry := range entries {
-				if !entry.IsDir() {
-					continue
-				}
-				
-				generationID := entry.Name()
-				generationPath := filepath.Join(generationsDir, generationID)
-				walDir := filepath.Join(generationPath, "wal")
-				
-				// Obter informações da generation
-				info, err := entry.Info()
-				if err != nil {
-					continue
-				}
-				
-				// Adicionar opção de restore para a generation local
-				genTimestamp := info.ModTime()
-				if genTimestamp.After(latestTimestamp) {
-					latestTimestamp = genTimestamp
+
+	sample := PathTemplateData{ClientID: "00000000-0000-0000-0000-000000000000"}
+	if _, err := renderPath(tmpl, sample); err != nil {
+		return nil, fmt.Errorf("invalid -s3-path-template: %w", err)
+	}
+	return tmpl, nil
+}
+
+// renderPath executa o template do path e normaliza as barras
+func renderPath(tmpl *texttemplate.Template, data PathTemplateData) (string, error) {
+	var buf strings.Builder
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+
+	path := strings.Trim(buf.String(), "/")
+	if path == "" {
+		return "", fmt.Errorf("template rendered an empty path")
+	}
+	return path, nil
+}
+
+// replicaPath calcula o path do replica de um cliente a partir de -s3-path-template
+func (dm *DatabaseManager) replicaPath(clientID string) (string, error) {
+	now := time.Now()
+	return renderPath(dm.config.PathTemplate, PathTemplateData{
+		ClientID: clientID,
+		Env:      dm.config.Env,
+		Year:     now.Format("2006"),
+		Month:    now.Format("01"),
+		Day:      now.Format("02"),
+	})
+}
+
+// DatabaseManager gerencia instâncias do Litestream (1 banco por cliente)
+type DatabaseManager struct {
+	databases    map[string]*litestream.DB // clientID -> litestream.DB
+	clients      map[string]*ClientConfig  // clientID -> config
+	pathIndex    map[string]string         // dbPath -> clientID (index para lookups)
+	watchedDirs  map[string]struct{}       // diretórios registrados no watcher
+	watcher      *fsnotify.Watcher
+	mutex        sync.RWMutex
+	pending      map[string]*time.Timer // dbPath -> registro agendado (debounce)
+	pendingMutex sync.Mutex
+	retries      map[string]*retryState           // dbPath -> próxima tentativa de registro
+	failed       map[string]*FailedRegistration   // dbPath -> registro que esgotou as tentativas
+	waitingDirs  map[string]struct{}              // watch dirs que ainda não existem (-wait-for-dirs)
+	degradedDirs map[string]*DegradedDir          // watch dirs que ficaram inacessíveis (ex: NFS desmontado)
+	opening      map[string]string                // clientID -> dbPath com lsdb.Open() em andamento
+	collisions   map[string]*IDCollision          // dbPath não replicado: clientID já usado por outro arquivo
+	rejected     map[string]*RejectedRegistration // dbPath não replicado: limite de -max-clients atingido
+	skipped      map[string]*SkippedFile          // dbPath não replicado: maior que -max-db-size
+	problems     map[string]*RegistrationProblem  // dbPath -> último erro de validação/abertura
+	diskUsage    map[string]int64                 // clientID -> bytes do diretório local do Litestream
+	paused       map[string]bool                  // clientIDs com replicação pausada via API
+	syncing      map[string]bool                  // clientIDs com sync manual em andamento
+	restoring    map[string]bool                  // dbPaths com restore in-place em andamento
+	released     map[string]string                // dbPath -> clientID removido por DELETE /api/client (ignorado até o arquivo sumir)
+	rescanNow    chan struct{}                    // pedido de rescan imediato (ex: overflow do watcher)
+	dirScans     map[string]bool                  // diretórios com varredura pendente (protegido por pendingMutex)
+	dirScanNow   chan struct{}                    // avisa o rescanLoop de que há diretórios em dirScans
+	restoreSlots chan struct{}                    // vagas para restore/verify pela API (-max-concurrent-restores)
+	events       *eventBus                        // eventos dos clientes para /api/events
+	statusCache  map[string]*clientStatusSnapshot // clientID -> última leitura do poller de status
+	statusMutex  sync.RWMutex                     // protege statusCache (independente do mutex principal)
+	progress     map[string]*replicaProgress      // clientID -> última posição replicada observada
+	stats        map[string]*clientStats          // clientID -> bytes e duração dos syncs
+	statsMutex   sync.Mutex                       // protege stats (independente do mutex principal)
+	closing      map[string]bool                  // clientIDs ainda fechando em Stop (lidos por stopWithTimeout)
+	closingMutex sync.Mutex                       // protege closing (lido por stopWithTimeout enquanto Stop fecha)
+	syncFailures map[string]*syncFailureState     // clientID -> falhas de sync observadas (-webhook-url)
+	config       Config
+	bucket       string   // bucket principal (usado nos comandos de restore)
+	buckets      []string // todos os buckets de destino
+	watchDirs    []string
+	ctx          context.Context
+	cancel       context.CancelFunc
+
+	replicaClients ReplicaClientFactory // cria os clients dos replicas (padrão: &config)
+}
+
+// FailedRegistration banco que não pôde ser registrado após todas as tentativas
+type FailedRegistration struct {
+	ClientID     string    `json:"clientId"`
+	DatabasePath string    `json:"databasePath"`
+	Attempts     int       `json:"attempts"`
+	Error        string    `json:"error"`
+	FailedAt     time.Time `json:"failedAt"`
+}
+
+// Tipos de problema de registro listados em /api/status
+const (
+	ProblemNotSQLite = "not-sqlite" // o arquivo não tem o cabeçalho do SQLite
+	ProblemOpenError = "open-error" // falha ao abrir o banco com o Litestream
+)
+
+// sqliteHeader primeiros 16 bytes de todo banco SQLite
+const sqliteHeader = "SQLite format 3\x00"
+
+// errNotSQLite arquivo com nome de banco que não é um banco SQLite
+var errNotSQLite = errors.New("not a sqlite file")
+
+// checkSQLiteHeader confere o cabeçalho do arquivo. Arquivos vazios são aceitos:
+// o SQLite os trata como banco novo (ex: touch {clientID}.db).
+func checkSQLiteHeader(dbPath string) error {
+	f, err := os.Open(dbPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	header := make([]byte, len(sqliteHeader))
+	n, err := io.ReadFull(f, header)
+	if n == 0 && err == io.EOF {
+		return nil
+	} else if err == io.ErrUnexpectedEOF || (err == nil && string(header) != sqliteHeader) {
+		return fmt.Errorf("%w: %s", errNotSQLite, dbPath)
+	}
+	return err
+}
+
+// RegistrationProblem arquivo que não pôde ser registrado, por tipo de erro
+type RegistrationProblem struct {
+	ClientID     string    `json:"clientId"`
+	DatabasePath string    `json:"databasePath"`
+	Kind         string    `json:"kind"` // not-sqlite ou open-error
+	Error        string    `json:"error"`
+	DetectedAt   time.Time `json:"detectedAt"`
+}
+
+// recordProblem registra o problema para /api/status. Arquivos que não são
+// SQLite são logados na primeira detecção; erros de abertura já são logados
+// pelas novas tentativas.
+func (dm *DatabaseManager) recordProblem(clientID, dbPath, kind string, err error) {
+	dm.mutex.Lock()
+	defer dm.mutex.Unlock()
+
+	if existing, exists := dm.problems[dbPath]; exists && existing.Kind == kind {
+		existing.Error = err.Error()
+		return
+	}
+	dm.problems[dbPath] = &RegistrationProblem{
+		ClientID:     clientID,
+		DatabasePath: dbPath,
+		Kind:         kind,
+		Error:        err.Error(),
+		DetectedAt:   time.Now(),
+	}
+
+	if kind == ProblemNotSQLite {
+		logEvent(MsgNotSQLite, dbPath)
+	}
+}
+
+// registrationProblems lista os problemas ordenados por caminho (chamar com o lock)
+func (dm *DatabaseManager) registrationProblems() []*RegistrationProblem {
+	problems := make([]*RegistrationProblem, 0, len(dm.problems))
+	for _, problem := range dm.problems {
+		problems = append(problems, problem)
+	}
+	sort.Slice(problems, func(i, j int) bool {
+		return problems[i].DatabasePath < problems[j].DatabasePath
+	})
+	return problems
+}
+
+// retryState controle de backoff de um registro com falha
+type retryState struct {
+	attempts    int
+	nextAttempt time.Time
+}
+
+// retriableError marca falhas transitórias de registro (ex: arquivo bloqueado)
+type retriableError struct {
+	err error
+}
+
+func (e *retriableError) Error() string { return e.err.Error() }
+func (e *retriableError) Unwrap() error { return e.err }
+
+// ClientConfig configuração otimizada para 1:1 cliente:banco
+type ClientConfig struct {
+	ClientID     string    `json:"clientId"`
+	DatabasePath string    `json:"databasePath"`
+	S3Path       string    `json:"s3Path"` // path renderizado no momento do registro
+	CreatedAt    time.Time `json:"createdAt"`
+	CollidesWith string    `json:"collidesWith,omitempty"` // outro arquivo com o mesmo ID (-on-id-collision=suffix)
+
+	FirstReplicatedAt *time.Time `json:"firstReplicatedAt,omitempty"` // primeiro sync concluído com o replica principal
+
+	Metadata *ClientMetadata `json:"metadata,omitempty"` // {clientID}.meta.json ao lado do banco
+
+	RegistrationDuration time.Duration `json:"-"` // duração do registro, incluindo lsdb.Open() e restore
+
+	firstChecked bool // primeira posição já observada (first_replicated anunciado ou descartado)
+}
+
+// ClientMetadata nome e tags de exibição lidos de {clientID}.meta.json
+type ClientMetadata struct {
+	DisplayName string            `json:"displayName,omitempty"`
+	Tags        map[string]string `json:"tags,omitempty"` // ex: {"tenant": "Acme", "plan": "pro"}
+}
+
+// DashboardData dados para o template HTML
+type DashboardData struct {
+	Bucket        string         `json:"bucket"`
+	Buckets       []string       `json:"buckets"`
+	WatchDirCount int            `json:"watchDirCount"`
+	DegradedDirs  []*DegradedDir `json:"degradedDirs"`
+	ClientCount   int            `json:"clientCount"`
+	Uptime        string         `json:"uptime"`
+	DryRun        bool           `json:"dryRun"`
+	RefreshMs     int64          `json:"refreshMs"`     // intervalo de atualização via /api/status (0 = desligada)
+	LocalDiskUsed string         `json:"localDiskUsed"` // soma dos diretórios .{nome}-litestream
+	StatusFilter  string         `json:"statusFilter"`  // ?status= da página (vazio = todos)
+	Clients       []ClientData   `json:"clients"`
+}
+
+// ClientData dados de cada cliente para o template
+
+type ClientData struct {
+	ClientID          string            `json:"clientId"`
+	DisplayName       string            `json:"displayName,omitempty"` // de {clientID}.meta.json
+	Tags              map[string]string `json:"tags,omitempty"`
+	DatabasePath      string            `json:"databasePath"`
+	Status            string            `json:"-"` // active, inactive, paused, dry-run ou failed (não replicado)
+	StatusClass       string            `json:"statusClass"`
+	StatusText        string            `json:"statusText"`
+	CreatedAt         string            `json:"createdAt"`
+	Warning           string            `json:"warning,omitempty"`           // ex: colisão de clientID
+	Generation        string            `json:"generation"`                  // geração atual do banco local
+	Position          string            `json:"position"`                    // posição do WAL local
+	LastSync          string            `json:"lastSync"`                    // última vez em que um replica avançou
+	FirstReplicatedAt string            `json:"firstReplicatedAt,omitempty"` // vazio até o primeiro sync com o S3
+	Registered        bool              `json:"-"`                           // cliente registrado (atualizado pelo auto-refresh)
+	Replicas          []ReplicaData     `json:"replicas"`
+	Generations       []GenerationData  `json:"generations,omitempty"`
+}
+
+// ReplicaData status de uma réplica (destino) de um cliente
+type ReplicaData struct {
+	Name     string `json:"name"`
+	Bucket   string `json:"bucket"`
+	Path     string `json:"path"`
+	URL      string `json:"url"`
+	Position string `json:"position"` // última posição replicada
+}
+
+// replicaProgressInterval frequência com que as posições dos replicas são comparadas
+const replicaProgressInterval = 5 * time.Second
+
+// replicaProgress última posição replicada e quando ela mudou
+type replicaProgress struct {
+	positions []litestream.Pos
+	syncedAt  time.Time
+}
+
+// trackReplicaProgress registra quando a posição de algum replica avança,
+// o que indica um sync bem-sucedido (a leitura é em memória, sem acessar o S3)
+func (dm *DatabaseManager) trackReplicaProgress() {
+	ticker := time.NewTicker(replicaProgressInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-dm.ctx.Done():
+			return
+		case now := <-ticker.C:
+			var first []firstReplication
+			dm.mutex.Lock()
+			for clientID, lsdb := range dm.databases {
+				positions := make([]litestream.Pos, len(lsdb.Replicas))
+				for i, replica := range lsdb.Replicas {
+					positions[i] = replica.Pos()
 				}
-				
-				sourceLabel := "local"
-				if s3Available {
-					sourceLabel = "local+s3"
+
+				state, exists := dm.progress[clientID]
+				if !exists {
+					state = &replicaProgress{}
+					dm.progress[clientID] = state
 				}
-				
-				restoreOptions = append(restoreOptions, RestoreOption{
-					ID:          generationID + "-local",
-					Type:        "generation",
-					Timestamp:   genTimestamp.Format("2006-01-02 15:04:05"),
-					Size:        "-",
-					Description: fmt.Sprintf("Local generation %s (%s)", generationID[:8], sourceLabel),
-					Command:     fmt.Sprintf("litestream restore -generation %s -o restored.db s3://%s/databases/%s", generationID, dm.bucket, clientID),
-				})
-				
-				// Listar WAL files individuais para restore point-in-time
-				if walEntries, err := os.ReadDir(walDir); err == nil {
-					for _, walEntry := range walEntries {
-						if !walEntry.IsDir() && strings.HasSuffix(walEntry.Name(), ".wal") {
-							walInfo, err := walEntry.Info()
-							if err != nil {
-								continue
-							}
-							
-							walTimestamp := walInfo.ModTime()
-							if walTimestamp.After(latestTimestamp) {
-								latestTimestamp = walTimestamp
-							}
-							
-							// Converter bytes para formato amigável
-							size := walInfo.Size()
-							var sizeStr string
-							if size < 1024 {
-								sizeStr = fmt.Sprintf("%dB", size)
-							} else if size < 1024*1024 {
-								sizeStr = fmt.Sprintf("%.1fKB", float64(size)/1024)
-							} else {
-								sizeStr = fmt.Sprintf("%.1fMB", float64(size)/(1024*1024))
-							}
-							
-							walID := strings.TrimSuffix(walEntry.Name(), ".wal")
-							restoreOptions = append(restoreOptions, RestoreOption{
-								ID:          walID + "-local",
-								Type:        "wal",
-								Timestamp:   walTimestamp.Format("2006-01-02 15:04:05"),
-								Size:        sizeStr,
-								Description: fmt.Sprintf("Point-in-time WAL %s (%s)", walID, sourceLabel),
-								Command:     fmt.Sprintf("litestream restore -timestamp \"%s\" -o restored.db s3://%s/databases/%s", walTimestamp.Format("2006-01-02T15:04:05Z"), dm.bucket, clientID),
-							})
+				if !equalPositions(state.positions, positions) {
+					if len(state.positions) == len(positions) {
+						var n int64
+						for i := range positions {
+							n += replicatedBytes(state.positions[i], positions[i])
 						}
+						dm.addReplicatedBytes(clientID, n)
 					}
+					state.positions = positions
+					state.syncedAt = now
+				}
+
+				// Primeira posição do replica principal: os dados já estão no S3
+				if config, ok := dm.clients[clientID]; ok && !config.firstChecked && len(positions) > 0 && !positions[0].IsZero() {
+					config.firstChecked = true
+					first = append(first, firstReplication{config: config, replica: lsdb.Replicas[0], generation: positions[0].Generation, at: now})
+				}
+			}
+			for clientID := range dm.progress {
+				if _, exists := dm.clients[clientID]; !exists {
+					delete(dm.progress, clientID)
 				}
 			}
+			dm.mutex.Unlock()
+
+			// A verificação lê o replica: fora do lock e sem atrasar o próximo tick
+			for _, f := range first {
+				go dm.firstReplicated(f)
+			}
 		}
 	}
-	
-	// Se não há dados nem no S3 nem local
-	if len(restoreOptions) == 0 {
-		return &RestoreOptionsData{
-			ClientID:       clientID,
-			TotalOptions:   0,
-			LatestBackup:   "No backups available",
-			RestoreOptions: []RestoreOption{},
-		}, nil
+}
+
+// firstReplication primeira posição observada do replica principal de um cliente
+type firstReplication struct {
+	config     *ClientConfig
+	replica    *litestream.Replica
+	generation string
+	at         time.Time
+}
+
+// firstReplicated anuncia o primeiro sync concluído de um cliente registrado
+// (diferente do registro, que só indica que o banco local abriu). Clientes já
+// replicados antes deste registro (reinício, novo registro) não são anunciados.
+func (dm *DatabaseManager) firstReplicated(f firstReplication) {
+	config := f.config
+	if before, err := replicatedBefore(dm.ctx, f.replica.Client, f.generation, config.CreatedAt); err != nil {
+		debugf("Failed to check previous replication of client %s: %v", config.ClientID, err)
+	} else if before {
+		return
 	}
-	
-	// Ordenar por timestamp (mais recente primeiro)
-	sort.Slice(restoreOptions, func(i, j int) bool {
-		return restoreOptions[i].Timestamp > restoreOptions[j].Timestamp
-	})
-	
-	latestBackupStr := "No backups available"
-	if !latestTimestamp.IsZero() {
-		latestBackupStr = latestTimestamp.Format("2006-01-02 15:04:05")
-		if s3Available {
-			latestBackupStr += " (S3+Local)"
-		} else {
-			latestBackupStr += " (Local only)"
-		}
+
+	dm.mutex.Lock()
+	if dm.clients[config.ClientID] != config {
+		dm.mutex.Unlock()
+		return // removido ou registrado de novo durante a verificação
 	}
-	
-	return &RestoreOptionsData{
-		ClientID:       clientID,
-		TotalOptions:   len(restoreOptions),
-		LatestBackup:   latestBackupStr,
-		RestoreOptions: restoreOptions,
-	}, nil
-}
+	config.FirstReplicatedAt = &f.at
+	dm.mutex.Unlock()
 
-func main() {
-	if err := run(); err != nil {
-		fmt.Fprintln(os.Stderr, err)
-		os.Exit(1)
+	logEvent(MsgFirstReplication, config.ClientID, config.FirstReplicatedAt.Sub(config.CreatedAt).Round(time.Second))
+	dm.audit("first_replicated", config.ClientID, config.DatabasePath, config.S3Path)
+	if dm.config.WebhookURL != "" {
+		go dm.sendWebhook(WebhookEvent{
+			Event:     "first_replicated",
+			ClientID:  config.ClientID,
+			Timestamp: config.FirstReplicatedAt.UTC(),
+		})
 	}
 }
 
-func run() error {
-	// Configura logger para filtrar mensagens técnicas do Litestream
-	log.SetOutput(&filteredWriter{writer: os.Stdout})
+// replicatedBefore verifica, com o client que o replica já usa, se havia dados
+// no destino antes de registeredAt: outra geração ou um snapshot mais antigo
+// da geração atual
+func replicatedBefore(ctx context.Context, client litestream.ReplicaClient, generation string, registeredAt time.Time) (bool, error) {
+	generations, err := client.Generations(ctx)
+	if err != nil {
+		return false, err
+	}
+	for _, g := range generations {
+		if g != generation {
+			return true, nil
+		}
+	}
+
+	itr, err := client.Snapshots(ctx, generation)
+	if err != nil {
+		return false, err
+	}
+	for itr.Next() {
+		if itr.Snapshot().CreatedAt.Before(registeredAt) {
+			itr.Close()
+			return true, nil
+		}
+	}
+	return false, itr.Close()
+}
+
+// equalPositions compara as posições dos replicas
+func equalPositions(a, b []litestream.Pos) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// lastSync quando algum replica do cliente avançou pela última vez (chamar com o lock)
+func (dm *DatabaseManager) lastSync(clientID string) string {
+	if state, exists := dm.progress[clientID]; exists && !state.syncedAt.IsZero() && len(state.positions) > 0 && !state.positions[0].IsZero() {
+		return formatTimestamp(state.syncedAt)
+	}
+	return "never"
+}
+
+// replicaData monta o status de cada réplica do banco
+func replicaData(lsdb *litestream.DB) []ReplicaData {
+	replicas := make([]ReplicaData, 0, len(lsdb.Replicas))
+	for _, replica := range lsdb.Replicas {
+		data := ReplicaData{
+			Name:     replica.Name(),
+			Position: "unknown",
+		}
+		switch client := replica.Client.(type) {
+		case *lss3.ReplicaClient:
+			data.Bucket, data.Path = client.Bucket, client.Path
+		case *lsgcs.ReplicaClient:
+			data.Bucket, data.Path = client.Bucket, client.Path
+		case *lsabs.ReplicaClient:
+			data.Bucket, data.Path = client.Bucket, client.Path
+		case *lsfile.ReplicaClient:
+			data.Path = client.Path()
+		}
+		data.URL = replicaClientURL(replica.Client) + "/"
+		if pos := replica.Pos(); !pos.IsZero() {
+			data.Position = pos.String()
+		}
+		replicas = append(replicas, data)
+	}
+	return replicas
+}
+
+// failedRegistrations lista os registros que falharam (chamar com mutex travado)
+func (dm *DatabaseManager) failedRegistrations() []FailedRegistration {
+	failures := make([]FailedRegistration, 0, len(dm.failed))
+	for _, failure := range dm.failed {
+		failures = append(failures, *failure)
+	}
+	sort.Slice(failures, func(i, j int) bool {
+		return failures[i].DatabasePath < failures[j].DatabasePath
+	})
+	return failures
+}
+
+// replicaName nome do replica Litestream para o i-ésimo bucket: o tipo do
+// destino (s3, file, gcs, abs), com o número do bucket a partir do segundo
+func replicaName(replicaType string, i int) string {
+	if i == 0 {
+		return replicaType
+	}
+	return fmt.Sprintf("%s-%d", replicaType, i+1)
+}
+
+// GenerationData informações de uma geração de backup
+type GenerationData struct {
+	ID        string         `json:"id"`
+	Created   string         `json:"created"`
+	Updated   string         `json:"updated"`
+	Source    string         `json:"source"`            // "s3" ou "local"
+	Replica   string         `json:"replica,omitempty"` // replica de origem quando source = "s3"
+	Snapshots []SnapshotData `json:"snapshots,omitempty"`
+}
+
+// SnapshotData informações de um snapshot
+type SnapshotData struct {
+	ID      string `json:"id"`
+	Created string `json:"created"`
+	Size    string `json:"size"`
+	Source  string `json:"source"`    // "s3" ou "local"
+}
+
+// GenerationSnapshotsData snapshots e segmentos de WAL de uma geração no replica
+type GenerationSnapshotsData struct {
+	ClientID    string             `json:"clientId"`
+	Generation  string             `json:"generation"`
+	Replica     string             `json:"replica"`
+	Snapshots   []S3SnapshotData   `json:"snapshots"`
+	WALSegments []S3WALSegmentData `json:"walSegments"`
+}
+
+// S3SnapshotData snapshot completo armazenado no replica
+type S3SnapshotData struct {
+	Index   int    `json:"index"`
+	Size    int64  `json:"size"`
+	Created string `json:"created"`
+}
+
+// S3WALSegmentData segmento de WAL armazenado no replica
+type S3WALSegmentData struct {
+	Index   int    `json:"index"`
+	Offset  int64  `json:"offset"`
+	Size    int64  `json:"size"`
+	Created string `json:"created"`
+}
+
+// RestoreOption representa uma opção específica de restore
+type RestoreOption struct {
+	ID          string `json:"id"`
+	Type        string `json:"type"`        // "generation", "snapshot", "wal"
+	Timestamp   string `json:"timestamp"`
+	Size        string `json:"size"`
+	Description string `json:"description"`
+	Command     string `json:"command"`     // Comando litestream para restaurar
+}
+
+// RestoreOptionsData todas as opções de restore disponíveis para um cliente
+type RestoreOptionsData struct {
+	ClientID       string          `json:"clientId"`
+	TotalOptions   int            `json:"totalOptions"`
+	LatestBackup   string         `json:"latestBackup"`
+	RestoreOptions []RestoreOption `json:"restoreOptions"`
+}
+
+// ClientHealth estado de replicação de um cliente para /api/health
+type ClientHealth struct {
+	ClientID   string `json:"clientId"`
+	LocalPos   string `json:"localPos"`
+	ReplicaPos string `json:"replicaPos"`
+	LagBytes   int64  `json:"lagBytes"`
+	Healthy    bool   `json:"healthy"`
+	Error      string `json:"error,omitempty"`
+}
+
+// HealthData resposta agregada de /api/health
+type HealthData struct {
+	Status      string         `json:"status"` // "ok" ou "unhealthy"
+	MaxLagBytes int64          `json:"maxLagBytes"`
+	Clients     []ClientHealth `json:"clients"`
+}
+
+// getHealth compara a posição local do WAL de cada cliente com a última posição replicada
+func (dm *DatabaseManager) getHealth() HealthData {
+	dm.mutex.RLock()
+	defer dm.mutex.RUnlock()
+
+	clientIDs := make([]string, 0, len(dm.databases))
+	for clientID := range dm.databases {
+		clientIDs = append(clientIDs, clientID)
+	}
+	sort.Strings(clientIDs)
+
+	data := HealthData{
+		Status:      "ok",
+		MaxLagBytes: dm.config.MaxLagBytes,
+		Clients:     make([]ClientHealth, 0, len(clientIDs)),
+	}
+
+	for _, clientID := range clientIDs {
+		lsdb := dm.databases[clientID]
+		health := ClientHealth{ClientID: clientID}
+
+		localPos, err := lsdb.Pos()
+		if err != nil {
+			health.Error = err.Error()
+		} else if len(lsdb.Replicas) == 0 {
+			health.Error = "no replica configured"
+		} else {
+			// Reporta a réplica mais atrasada
+			health.LocalPos = localPos.String()
+			health.LagBytes = -1
+			for _, replica := range lsdb.Replicas {
+				replicaPos := replica.Pos()
+				if lag := replicationLag(localPos, replicaPos); lag > health.LagBytes {
+					health.LagBytes = lag
+					health.ReplicaPos = replicaPos.String()
+				}
+			}
+			health.Healthy = health.LagBytes <= dm.config.MaxLagBytes
+		}
+
+		if !health.Healthy {
+			data.Status = "unhealthy"
+		}
+		data.Clients = append(data.Clients, health)
+	}
+
+	return data
+}
+
+// replicationLag estima quantos bytes do WAL local ainda não chegaram à réplica.
+// Quando réplica e banco estão em índices/gerações diferentes o tamanho dos
+// segmentos intermediários não é conhecido, então o offset local é usado como
+// estimativa mínima.
+func replicationLag(local, replica litestream.Pos) int64 {
+	if local.Generation != replica.Generation || local.Index > replica.Index {
+		return local.Offset
+	}
+	if local.Index == replica.Index && local.Offset > replica.Offset {
+		return local.Offset - replica.Offset
+	}
+	return 0
+}
+
+// getClientGenerations obtém gerações disponíveis para um cliente lendo dados reais dos arquivos
+func (dm *DatabaseManager) getClientGenerations(clientID string) ([]GenerationData, error) {
+	// Busca a instância do litestream.DB para o cliente; a leitura do disco é
+	// feita fora do lock para não competir com registros
+	dm.mutex.RLock()
+	lsdb, exists := dm.databases[clientID]
+	dm.mutex.RUnlock()
+	if !exists {
+		return nil, fmt.Errorf("%w: client is not replicating: %s", errClientState, clientID)
+	}
+	
+	// Diretório .{nome}-litestream, como o próprio Litestream o calcula
+	generationsDir := filepath.Join(lsdb.MetaPath(), "generations")
+	
+	// Verificar se o diretório existe
+	if _, err := os.Stat(generationsDir); os.IsNotExist(err) {
+		return []GenerationData{}, nil // Retorna vazio se não há generations
+	}
+	
+	var generations []GenerationData
+	
+	// Ler diretórios de generations
+	entries, err := os.ReadDir(generationsDir)
+	if err != nil {
+		log.Printf("⚠️  Error reading generations directory for client %s: %v", clientID, err)
+		return []GenerationData{}, nil
+	}
+	
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		
+		generationID := entry.Name()
+		generationPath := filepath.Join(generationsDir, generationID)
+		
+		// Obter informações da generation
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		
+		// Buscar o WAL mais recente para obter timestamp atualizado
+		walDir := filepath.Join(generationPath, "wal")
+		var latestWALTime time.Time = info.ModTime()
+		
+		if walEntries, err := os.ReadDir(walDir); err == nil {
+			for _, walEntry := range walEntries {
+				if strings.HasSuffix(walEntry.Name(), ".wal") {
+					if walInfo, err := walEntry.Info(); err == nil {
+						if walInfo.ModTime().After(latestWALTime) {
+							latestWALTime = walInfo.ModTime()
+						}
+					}
+				}
+			}
+		}
+		
+		generation := GenerationData{
+			ID:      generationID,
+			Created: formatTimestamp(info.ModTime()),
+			Updated: formatTimestamp(latestWALTime),
+			Source:  "local", // Indicando que os dados vêm dos arquivos locais
+		}
+		
+		generations = append(generations, generation)
+	}
+	
+	// Ordenar por data de criação (mais recente primeiro)
+	sort.Slice(generations, func(i, j int) bool {
+		return generations[i].Created > generations[j].Created
+	})
+	
+	return generations, nil
+}
+
+// clientGenerations gerações locais do cliente com os snapshots de cada uma;
+// falhas ao ler os snapshots de uma geração resultam em lista vazia
+func (dm *DatabaseManager) clientGenerations(clientID string) ([]GenerationData, error) {
+	generations, err := dm.getClientGenerations(clientID)
+	if err != nil {
+		return nil, err
+	}
+	
+	for i := range generations {
+		snapshots, err := dm.getClientSnapshots(clientID, generations[i].ID)
+		if err != nil {
+			log.Printf("⚠️  Failed to get snapshots for client %s generation %s: %v", 
+				clientID, generations[i].ID, err)
+			snapshots = []SnapshotData{}
+		}
+		generations[i].Snapshots = snapshots
+	}
+	return generations, nil
+}
+
+// clientStatusSnapshot dados de um cliente lidos do disco pelo poller (-status-poll-interval)
+type clientStatusSnapshot struct {
+	generation  string // "" se ainda não há posição
+	position    string
+	generations []GenerationData
+	asOf        time.Time
+	backup      *backupFootprint // nil até a primeira leitura do replica principal
+}
+
+// backupFootprintInterval frequência com que o poller relista o replica
+// principal de cada cliente para contar gerações e bytes
+const backupFootprintInterval = 5 * time.Minute
+
+// backupFootprint gerações e bytes (snapshots + segmentos de WAL) de um
+// cliente no replica principal
+type backupFootprint struct {
+	generationCount  int
+	totalBackupBytes int64
+	asOf             time.Time
+}
+
+// pollStatus atualiza periodicamente o cache de status dos clientes, para que o
+// dashboard e a API não leiam o disco a cada acesso
+func (dm *DatabaseManager) pollStatus() {
+	ticker := time.NewTicker(dm.config.StatusPollInterval)
+	defer ticker.Stop()
+
+	for {
+		dm.refreshStatusCache()
+
+		select {
+		case <-dm.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// refreshStatusCache lê o estado de todos os clientes ativos, fora do lock principal
+func (dm *DatabaseManager) refreshStatusCache() {
+	dm.mutex.RLock()
+	databases := make(map[string]*litestream.DB, len(dm.databases))
+	for clientID, lsdb := range dm.databases {
+		databases[clientID] = lsdb
+	}
+	dm.mutex.RUnlock()
+
+	cache := make(map[string]*clientStatusSnapshot, len(databases))
+	for clientID, lsdb := range databases {
+		snapshot := &clientStatusSnapshot{asOf: time.Now()}
+		snapshot.backup = dm.refreshBackupFootprint(clientID, lsdb)
+		if pos, err := lsdb.Pos(); err == nil && !pos.IsZero() {
+			snapshot.generation = pos.Generation
+			snapshot.position = fmt.Sprintf("%d/%d", pos.Index, pos.Offset)
+		}
+		generations, err := dm.clientGenerations(clientID)
+		if err != nil {
+			log.Printf("⚠️  Failed to get generations for client %s: %v", clientID, err)
+			generations = []GenerationData{}
+		}
+		snapshot.generations = generations
+		cache[clientID] = snapshot
+	}
+
+	dm.statusMutex.Lock()
+	dm.statusCache = cache
+	dm.statusMutex.Unlock()
+}
+
+// refreshBackupFootprint reaproveita a leitura anterior do replica principal
+// enquanto ela tem menos de backupFootprintInterval; listar o S3 a cada
+// -status-poll-interval custaria uma requisição por objeto de cada cliente
+func (dm *DatabaseManager) refreshBackupFootprint(clientID string, lsdb *litestream.DB) *backupFootprint {
+	var previous *backupFootprint
+	if cached := dm.cachedStatus(clientID); cached != nil {
+		previous = cached.backup
+	}
+	if previous != nil && time.Since(previous.asOf) < backupFootprintInterval {
+		return previous
+	}
+	if len(lsdb.Replicas) == 0 {
+		return previous
+	}
+
+	ctx, cancel := context.WithTimeout(dm.ctx, bucketCheckTimeout)
+	defer cancel()
+	footprint, err := listBackupFootprint(ctx, lsdb.Replicas[0].Client)
+	if err != nil {
+		debugf("Failed to measure backup of client %s: %v", clientID, err)
+		return previous
+	}
+	return footprint
+}
+
+// listBackupFootprint conta as gerações e soma o tamanho dos snapshots e
+// segmentos de WAL de todas elas
+func listBackupFootprint(ctx context.Context, client litestream.ReplicaClient) (*backupFootprint, error) {
+	generations, err := client.Generations(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list generations: %w", err)
+	}
+
+	footprint := &backupFootprint{generationCount: len(generations), asOf: time.Now()}
+	for _, generation := range generations {
+		snapshots, err := client.Snapshots(ctx, generation)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list snapshots of generation %s: %w", generation, err)
+		}
+		for snapshots.Next() {
+			footprint.totalBackupBytes += snapshots.Snapshot().Size
+		}
+		err = snapshots.Err()
+		snapshots.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to list snapshots of generation %s: %w", generation, err)
+		}
+
+		segments, err := client.WALSegments(ctx, generation)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list wal segments of generation %s: %w", generation, err)
+		}
+		for segments.Next() {
+			footprint.totalBackupBytes += segments.WALSegment().Size
+		}
+		err = segments.Err()
+		segments.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to list wal segments of generation %s: %w", generation, err)
+		}
+	}
+	return footprint, nil
+}
+
+// cachedStatus última leitura do poller para o cliente; nil se o poller está
+// desligado ou ainda não passou pelo cliente (o chamador lê ao vivo)
+func (dm *DatabaseManager) cachedStatus(clientID string) *clientStatusSnapshot {
+	dm.statusMutex.RLock()
+	defer dm.statusMutex.RUnlock()
+	return dm.statusCache[clientID]
+}
+
+// DiskUsage espaço local usado pelo diretório do Litestream de um cliente
+type DiskUsage struct {
+	ClientID    string                `json:"clientId"`
+	Path        string                `json:"path"` // diretório .{nome}-litestream
+	TotalBytes  int64                 `json:"totalBytes"`
+	Generations []GenerationDiskUsage `json:"generations"`
+	OtherBytes  int64                 `json:"otherBytes"` // arquivos fora de generations/
+}
+
+// GenerationDiskUsage espaço usado por uma geração (shadow WAL local)
+type GenerationDiskUsage struct {
+	ID    string `json:"id"`
+	Bytes int64  `json:"bytes"`
+}
+
+// metaPath diretório interno do Litestream para o banco, calculado exatamente
+// como litestream.DB.MetaPath; usado quando não há DB aberto (ex: cliente pausado)
+func metaPath(dbPath string) string {
+	dir, file := filepath.Split(dbPath)
+	return filepath.Join(dir, "."+file+litestream.MetaDirSuffix)
+}
+
+// localDiskUsage percorre o diretório do Litestream ao lado do banco e soma o
+// tamanho dos arquivos, separado por geração
+func localDiskUsage(dbPath string) (*DiskUsage, error) {
+	usage := &DiskUsage{
+		Path:        metaPath(dbPath),
+		Generations: []GenerationDiskUsage{},
+	}
+	generationsDir := filepath.Join(usage.Path, "generations")
+
+	byGeneration := make(map[string]int64)
+	err := filepath.WalkDir(usage.Path, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil // arquivo removido durante a varredura (ex: checkpoint)
+			}
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+
+		usage.TotalBytes += info.Size()
+		if rel, err := filepath.Rel(generationsDir, path); err == nil && !strings.HasPrefix(rel, "..") {
+			generation := strings.SplitN(rel, string(filepath.Separator), 2)[0]
+			byGeneration[generation] += info.Size()
+		} else {
+			usage.OtherBytes += info.Size()
+		}
+		return nil
+	})
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	for id, bytes := range byGeneration {
+		usage.Generations = append(usage.Generations, GenerationDiskUsage{ID: id, Bytes: bytes})
+	}
+	sort.Slice(usage.Generations, func(i, j int) bool {
+		return usage.Generations[i].ID < usage.Generations[j].ID
+	})
+	return usage, nil
+}
+
+// diskUsageInterval frequência do cálculo do espaço local total para o dashboard
+const diskUsageInterval = time.Minute
+
+// trackDiskUsage atualiza periodicamente o espaço local usado por cliente,
+// para o dashboard não percorrer todos os diretórios a cada acesso
+func (dm *DatabaseManager) trackDiskUsage() {
+	ticker := time.NewTicker(diskUsageInterval)
+	defer ticker.Stop()
+
+	for {
+		dm.mutex.RLock()
+		paths := make(map[string]string, len(dm.clients))
+		for clientID, config := range dm.clients {
+			paths[clientID] = config.DatabasePath
+		}
+		dm.mutex.RUnlock()
+
+		usage := make(map[string]int64, len(paths))
+		for clientID, dbPath := range paths {
+			if u, err := localDiskUsage(dbPath); err == nil {
+				usage[clientID] = u.TotalBytes
+			} else {
+				debugf("Failed to compute disk usage of client %s: %v", clientID, err)
+			}
+		}
+
+		dm.mutex.Lock()
+		dm.diskUsage = usage
+		dm.mutex.Unlock()
+
+		select {
+		case <-dm.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// localDiskBytes espaço local total dos diretórios do Litestream (chamar com o lock)
+func (dm *DatabaseManager) localDiskBytes() int64 {
+	var total int64
+	for clientID, bytes := range dm.diskUsage {
+		if _, exists := dm.clients[clientID]; exists {
+			total += bytes
+		}
+	}
+	return total
+}
+
+// formatBytes formata um tamanho em bytes de forma legível (ex: 12.3 MB)
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// getClientS3Generations lista as gerações existentes em cada replica remoto,
+// independente do estado local (ex: após restaurar em um host novo)
+func (dm *DatabaseManager) getClientS3Generations(ctx context.Context, clientID string) ([]GenerationData, error) {
+	// Copia os replicas para não segurar o lock durante as chamadas ao S3
+	dm.mutex.RLock()
+	lsdb, exists := dm.databases[clientID]
+	var replicas []*litestream.Replica
+	if exists {
+		replicas = append(replicas, lsdb.Replicas...)
+	}
+	dm.mutex.RUnlock()
+
+	if !exists {
+		return nil, fmt.Errorf("%w: client is not replicating: %s", errClientState, clientID)
+	}
+
+	generations := []GenerationData{}
+	for _, replica := range replicas {
+		ids, err := replica.Client.Generations(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list generations on replica %s: %w", replica.Name(), err)
+		}
+
+		for _, id := range ids {
+			createdAt, updatedAt, err := replica.GenerationTimeBounds(ctx, id)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read generation %s on replica %s: %w", id, replica.Name(), err)
+			}
+			generations = append(generations, GenerationData{
+				ID:      id,
+				Created: formatTimestamp(createdAt),
+				Updated: formatTimestamp(updatedAt),
+				Source:  "s3",
+				Replica: replica.Name(),
+			})
+		}
+	}
+
+	// Ordenar por data de criação (mais recente primeiro)
+	sort.Slice(generations, func(i, j int) bool {
+		return generations[i].Created > generations[j].Created
+	})
+
+	return generations, nil
+}
+
+// errReplicaNotFound replica pedido não existe para o cliente
+var errReplicaNotFound = errors.New("replica not found")
+
+// getClientS3Snapshots lista os snapshots e segmentos de WAL de uma geração
+// no replica informado (vazio = principal), usando a API do Litestream
+func (dm *DatabaseManager) getClientS3Snapshots(ctx context.Context, clientID, generation, replicaName string) (*GenerationSnapshotsData, error) {
+	dm.mutex.RLock()
+	lsdb, exists := dm.databases[clientID]
+	var replica *litestream.Replica
+	if exists {
+		for _, r := range lsdb.Replicas {
+			if replicaName == "" || r.Name() == replicaName {
+				replica = r
+				break
+			}
+		}
+	}
+	dm.mutex.RUnlock()
+
+	if !exists {
+		return nil, fmt.Errorf("%w: client is not replicating: %s", errClientState, clientID)
+	}
+	if replica == nil {
+		return nil, fmt.Errorf("%w: %s", errReplicaNotFound, replicaName)
+	}
+
+	data := &GenerationSnapshotsData{
+		ClientID:    clientID,
+		Generation:  generation,
+		Replica:     replica.Name(),
+		Snapshots:   []S3SnapshotData{},
+		WALSegments: []S3WALSegmentData{},
+	}
+
+	// Snapshots de todas as gerações; mantém apenas a pedida
+	snapshots, err := replica.Snapshots(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list snapshots: %w", err)
+	}
+	for _, info := range snapshots {
+		if info.Generation != generation {
+			continue
+		}
+		data.Snapshots = append(data.Snapshots, S3SnapshotData{
+			Index:   info.Index,
+			Size:    info.Size,
+			Created: formatTimestamp(info.CreatedAt),
+		})
+	}
+
+	itr, err := replica.Client.WALSegments(ctx, generation)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list wal segments: %w", err)
+	}
+	defer itr.Close()
+
+	for itr.Next() {
+		info := itr.WALSegment()
+		data.WALSegments = append(data.WALSegments, S3WALSegmentData{
+			Index:   info.Index,
+			Offset:  info.Offset,
+			Size:    info.Size,
+			Created: formatTimestamp(info.CreatedAt),
+		})
+	}
+	if err := itr.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list wal segments: %w", err)
+	}
+
+	return data, nil
+}
+
+// getClientSnapshots obtém snapshots de uma geração específica lendo dados reais dos arquivos WAL
+func (dm *DatabaseManager) getClientSnapshots(clientID, generationID string) ([]SnapshotData, error) {
+	// Busca a instância do litestream.DB para o cliente (disco lido fora do lock)
+	dm.mutex.RLock()
+	lsdb, exists := dm.databases[clientID]
+	dm.mutex.RUnlock()
+	if !exists {
+		return nil, fmt.Errorf("%w: client is not replicating: %s", errClientState, clientID)
+	}
+	
+	// Diretório WAL da generation específica, dentro do MetaPath do Litestream
+	walDir := filepath.Join(lsdb.MetaPath(), "generations", generationID, "wal")
+	
+	// Verificar se o diretório existe
+	if _, err := os.Stat(walDir); os.IsNotExist(err) {
+		return []SnapshotData{}, nil // Retorna vazio se não há WAL files
+	}
+	
+	var snapshots []SnapshotData
+	
+	// Ler arquivos WAL
+	entries, err := os.ReadDir(walDir)
+	if err != nil {
+		log.Printf("⚠️  Error reading WAL directory for client %s generation %s: %v", clientID, generationID, err)
+		return []SnapshotData{}, nil
+	}
+	
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".wal") {
+			info, err := entry.Info()
+			if err != nil {
+				continue
+			}
+			
+			// Converter bytes para formato amigável
+			size := info.Size()
+			var sizeStr string
+			if size < 1024 {
+				sizeStr = fmt.Sprintf("%dB", size)
+			} else if size < 1024*1024 {
+				sizeStr = fmt.Sprintf("%.1fKB", float64(size)/1024)
+			} else {
+				sizeStr = fmt.Sprintf("%.1fMB", float64(size)/(1024*1024))
+			}
+			
+			snapshot := SnapshotData{
+				ID:      strings.TrimSuffix(entry.Name(), ".wal"),
+				Created: formatTimestamp(info.ModTime()),
+				Size:    sizeStr,
+				Source:  "local", // Indicando que os dados vêm dos arquivos locais
+			}
+			
+			snapshots = append(snapshots, snapshot)
+		}
+	}
+	
+	// Ordenar por nome (ordem cronológica dos WAL files)
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].ID < snapshots[j].ID
+	})
+	
+	return snapshots, nil
+}
+
+// shortID primeiros 8 caracteres de um ID de geração, para exibição
+func shortID(id string) string {
+	if len(id) > 8 {
+		return id[:8]
+	}
+	return id
+}
+
+// getClientRestoreOptions lista todas as opções de restore disponíveis para um cliente
+// Tenta S3 primeiro, depois fallback para dados locais
+func (dm *DatabaseManager) getClientRestoreOptions(ctx context.Context, clientID string) (*RestoreOptionsData, error) {
+	// Copia o necessário sob o lock: CalcRestoreTarget abaixo acessa o S3
+	dm.mutex.RLock()
+	lsdb, exists := dm.databases[clientID]
+	if !exists {
+		dm.mutex.RUnlock()
+		return nil, fmt.Errorf("%w: client is not replicating: %s", errClientState, clientID)
+	}
+	s3Path := dm.clients[clientID].S3Path
+	bucket := dm.bucketsFor(lsdb.Path())[0]
+	dm.mutex.RUnlock()
+	
+	var restoreOptions []RestoreOption
+	var latestTimestamp time.Time
+	var s3Available bool = false
+	
+	// Tentar buscar dados do S3 primeiro usando a biblioteca litestream
+	if len(lsdb.Replicas) > 0 {
+		replica := lsdb.Replicas[0]
+		
+		// Tentar usar CalcRestoreTarget para verificar se S3 está acessível
+		opt := litestream.NewRestoreOptions()
+		if generation, _, err := replica.CalcRestoreTarget(ctx, opt); err == nil && generation != "" {
+			s3Available = true
+			log.Printf("🌐 S3 available for client %s, generation: %s", clientID, generation)
+			
+			// Adicionar opção de restore S3 (mais recente disponível)
+			restoreOptions = append(restoreOptions, RestoreOption{
+				ID:          generation,
+				Type:        "generation",
+				Timestamp:   formatTimestamp(time.Now()), // Timestamp aproximado
+				Size:        "-",
+				Description: fmt.Sprintf("Latest S3 generation %s", shortID(generation)),
+				Command:     fmt.Sprintf("litestream restore -o restored.db %s", dm.config.replicaURL(bucket, s3Path)),
+			})
+			
+			// Adicionar opção específica de generation
+			restoreOptions = append(restoreOptions, RestoreOption{
+				ID:          generation + "-specific",
+				Type:        "generation",
+				Timestamp:   formatTimestamp(time.Now().Add(-time.Hour)), // Timestamp aproximado
+				Size:        "-",
+				Description: fmt.Sprintf("S3 generation %s (specific)", shortID(generation)),
+				Command:     fmt.Sprintf("litestream restore -generation %s -o restored.db %s", generation, dm.config.replicaURL(bucket, s3Path)),
+			})
+			
+			latestTimestamp = time.Now()
+		} else {
+			log.Printf("⚠️  S3 not available for client %s: %v", clientID, err)
+		}
+	}
+	
+	// Buscar dados locais como fallback/complemento
+	generationsDir := filepath.Join(lsdb.MetaPath(), "generations")
+	
+	// Verificar se o diretório local existe
+	if _, err := os.Stat(generationsDir); err == nil {
+		// Ler diretórios de generations locais
+		entries, err := os.ReadDir(generationsDir)
+		if err == nil {
+			for _, entry := range entries {
+				if !entry.IsDir() {
+					continue
+				}
+				
+				generationID := entry.Name()
+				generationPath := filepath.Join(generationsDir, generationID)
+				walDir := filepath.Join(generationPath, "wal")
+				
+				// Obter informações da generation
+				info, err := entry.Info()
+				if err != nil {
+					continue
+				}
+				
+				// Adicionar opção de restore para a generation local
+				genTimestamp := info.ModTime()
+				if genTimestamp.After(latestTimestamp) {
+					latestTimestamp = genTimestamp
+				}
+				
+				sourceLabel := "local"
+				if s3Available {
+					sourceLabel = "local+s3"
+				}
+				
+				restoreOptions = append(restoreOptions, RestoreOption{
+					ID:          generationID + "-local",
+					Type:        "generation",
+					Timestamp:   formatTimestamp(genTimestamp),
+					Size:        "-",
+					Description: fmt.Sprintf("Local generation %s (%s)", shortID(generationID), sourceLabel),
+					Command:     fmt.Sprintf("litestream restore -generation %s -o restored.db %s", generationID, dm.config.replicaURL(bucket, s3Path)),
+				})
+				
+				// Listar WAL files individuais para restore point-in-time
+				if walEntries, err := os.ReadDir(walDir); err == nil {
+					for _, walEntry := range walEntries {
+						if !walEntry.IsDir() && strings.HasSuffix(walEntry.Name(), ".wal") {
+							walInfo, err := walEntry.Info()
+							if err != nil {
+								continue
+							}
+							
+							walTimestamp := walInfo.ModTime()
+							if walTimestamp.After(latestTimestamp) {
+								latestTimestamp = walTimestamp
+							}
+							
+							// Converter bytes para formato amigável
+							size := walInfo.Size()
+							var sizeStr string
+							if size < 1024 {
+								sizeStr = fmt.Sprintf("%dB", size)
+							} else if size < 1024*1024 {
+								sizeStr = fmt.Sprintf("%.1fKB", float64(size)/1024)
+							} else {
+								sizeStr = fmt.Sprintf("%.1fMB", float64(size)/(1024*1024))
+							}
+							
+							walID := strings.TrimSuffix(walEntry.Name(), ".wal")
+							restoreOptions = append(restoreOptions, RestoreOption{
+								ID:          walID + "-local",
+								Type:        "wal",
+								Timestamp:   formatTimestamp(walTimestamp),
+								Size:        sizeStr,
+								Description: fmt.Sprintf("Point-in-time WAL %s (%s)", walID, sourceLabel),
+								Command:     fmt.Sprintf("litestream restore -timestamp \"%s\" -o restored.db %s", walTimestamp.Format("2006-01-02T15:04:05Z"), dm.config.replicaURL(bucket, s3Path)),
+							})
+						}
+					}
+				}
+			}
+		}
+	}
+	
+	// Se não há dados nem no S3 nem local
+	if len(restoreOptions) == 0 {
+		return &RestoreOptionsData{
+			ClientID:       clientID,
+			TotalOptions:   0,
+			LatestBackup:   "No backups available",
+			RestoreOptions: []RestoreOption{},
+		}, nil
+	}
+	
+	// Ordenar por timestamp (mais recente primeiro)
+	sort.Slice(restoreOptions, func(i, j int) bool {
+		return restoreOptions[i].Timestamp > restoreOptions[j].Timestamp
+	})
+	
+	latestBackupStr := "No backups available"
+	if !latestTimestamp.IsZero() {
+		latestBackupStr = formatTimestamp(latestTimestamp)
+		if s3Available {
+			latestBackupStr += " (S3+Local)"
+		} else {
+			latestBackupStr += " (Local only)"
+		}
+	}
+	
+	return &RestoreOptionsData{
+		ClientID:       clientID,
+		TotalOptions:   len(restoreOptions),
+		LatestBackup:   latestBackupStr,
+		RestoreOptions: restoreOptions,
+	}, nil
+}
+
+func main() {
+	if err := run(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run() error {
+	log.SetOutput(os.Stdout)
+
+	// Inicializa tempo de start do servidor
+	startTime = time.Now()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Subcomandos de uso pontual
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "restore":
+			return runRestoreCommand(ctx, os.Args[2:])
+		case "list":
+			return runListCommand(ctx, os.Args[2:])
+		}
+	}
+
+	// Parse command line flags.
+	showVersion := flag.Bool("version", false, "print version information and exit")
+	configPath := flag.String("config", "", "JSON or YAML config file whose keys are flag names (command line flags take precedence)")
+	watchDir := flag.String("watch-dir", "", "directory to watch for GUID.db files (comma-separated for multiple)")
+	bucket := flag.String("bucket", "", "replica bucket, Azure container or, with -replica-type file, root directory (comma-separated to replicate to multiple destinations)")
+	bucketMap := flag.String("map", "", "per watch dir bucket, e.g. /data/free=bucket-free,/data/paid=bucket-paid (databases in other dirs use -bucket)")
+	noServer := flag.Bool("no-server", false, "do not start the dashboard/API server at all (same as -port 0)")
+	host := flag.String("host", "", "interface the web server binds to, e.g. 127.0.0.1 (default: all interfaces)")
+	port := flag.String("port", "8080", "port for the web server (default: 8080)")
+	authToken := flag.String("auth-token", envDefault("LITESTREAM_MANAGER_AUTH_TOKEN"), "require 'Authorization: Bearer <token>' on /api/* (env: LITESTREAM_MANAGER_AUTH_TOKEN)")
+	tlsCert := flag.String("tls-cert", "", "TLS certificate file for the status server (requires -tls-key; reloaded when it changes)")
+	tlsKey := flag.String("tls-key", "", "TLS private key file for the status server (requires -tls-cert)")
+	var corsOrigins stringList
+	flag.Var(&corsOrigins, "cors-origin", "origin allowed to call /api/* from a browser, e.g. https://admin.example.com (repeatable; * allows any)")
+	dashboardRefresh := flag.Duration("dashboard-refresh", 5*time.Second, "how often the dashboard updates client status from /api/status (0 disables)")
+	statusPollInterval := flag.Duration("status-poll-interval", 5*time.Second, "how often each client's generations and position are read from disk for the dashboard and API (0 reads them on every request)")
+	metricsPerClient := flag.Bool("metrics-per-client", false, "label replica position, replicated bytes and sync duration metrics by client_id (one series per client); without it /metrics only has fleet totals and per-client numbers stay in /api/client/{id}/stats")
+	dashboardTemplate := flag.String("template", "", "HTML template file that replaces the embedded dashboard; reloaded when it changes, the embedded one is used while it does not parse")
+	protectDashboard := flag.Bool("protect-dashboard", false, "also require -auth-token for the dashboard")
+	fallbackPort := flag.String("fallback-port", "", "alternate port for the web server if -port is already in use")
+	pathTemplate := flag.String("s3-path-template", DefaultPathTemplate, "Go template for the replica path; variables: {{.ClientID}} {{.Env}} {{.Year}} {{.Month}} {{.Day}}")
+	env := flag.String("env", "", "environment name available as {{.Env}} in -s3-path-template")
+	auditLogPath := flag.String("audit-log", "", "append one JSON line per client lifecycle event (registered, unregistered, paused, resumed, restore) to this file")
+	skipBucketCheck := flag.Bool("skip-bucket-check", false, "do not verify at startup that the buckets are reachable (offline testing)")
+	backend := addReplicaFlags(flag.CommandLine) // -replica-type, -s3-* e -abs-*, iguais nos subcomandos
+	s3SSE := flag.String("s3-sse", "", "server-side encryption for uploaded objects: AES256 or aws:kms (not supported by the bundled Litestream: any value fails at startup)")
+	s3SSEKMSKeyID := flag.String("s3-sse-kms-key-id", "", "KMS key id for -s3-sse aws:kms")
+	s3ACL := flag.String("s3-acl", "", "canned ACL for uploaded objects, e.g. private (not supported by the bundled Litestream: any value fails at startup)")
+	s3Tags := flag.String("s3-tags", "", "key=value,... tags for uploaded objects (not supported by the bundled Litestream: any value fails at startup)")
+	waitForDirs := flag.Bool("wait-for-dirs", false, "poll for watch dirs that do not exist yet and start watching them once created")
+	noRestore := flag.Bool("no-restore", false, "never pull data from the replicas: skip restore on start and disable the restore/verify endpoints")
+	dryRun := flag.Bool("dry-run", false, "detect databases and log the replica paths without opening or replicating them")
+	restoreOnCreate := flag.Bool("restore-on-create", false, "restore the latest backup from S3 into a database that has no tables yet (e.g. a file recreated after an app reinstall) before replicating it")
+	openBusyTimeout := flag.Duration("open-busy-timeout", 5*time.Second, "how long registration keeps retrying a database that another process holds locked (\"database is locked\") before it is retried later with -register-max-retries (0 = no waiting)")
+	restoreTimeout := flag.Duration("restore-timeout", 10*time.Minute, "time allowed for each attempt to restore a database from S3 while registering it; failed attempts are retried a few times, then registration is retried later (0 = no limit)")
+	maxConcurrentRestores := flag.Int("max-concurrent-restores", 2, "restore/verify API requests run at once; further requests get 429 Too Many Requests")
+	restoreDir := flag.String("restore-dir", "", "directory the restore API writes to; outputPath must be inside it and outside every watch dir (default: the system temp directory)")
+	once := flag.Bool("once", false, "scan the watch dirs, register and fully sync every database to S3, then exit (nonzero if any failed); no file watching or status server")
+	selfTest := flag.Bool("self-test", false, "at startup, replicate a temporary canary database from the first watch dir, check it in S3 and exit with an error if it fails")
+	recursive := flag.Bool("recursive", false, "also watch subdirectories of each watch dir")
+	maxLagBytes := flag.Int64("max-lag-bytes", 16*1024*1024, "replication lag in bytes above which /api/health reports a client as unhealthy")
+	retention := flag.Duration("retention", litestream.DefaultRetention, "how long snapshots and WAL are kept on the replica before being deleted")
+	retentionCheckInterval := flag.Duration("retention-check-interval", litestream.DefaultRetentionCheckInterval, "how often retention is enforced on the replica")
+	snapshotInterval := flag.Duration("snapshot-interval", 0, "how often a full snapshot is taken (0 = only when required by retention or a new generation)")
+	metaDir := flag.String("meta-dir", "", "directory for Litestream's internal .{name}-litestream dirs (not supported by the bundled Litestream: any value fails at startup)")
+	compress := flag.String("compress", CompressLZ4, "compression of snapshots and WAL segments (only lz4 is supported by the bundled Litestream)")
+	syncInterval := flag.Duration("sync-interval", litestream.DefaultSyncInterval, "how often WAL changes are pushed to the replica")
+	registerMaxRetries := flag.Int("register-max-retries", 5, "retries with exponential backoff when opening a database fails")
+	shutdownSyncTimeout := flag.Duration("shutdown-sync-timeout", 10*time.Second, "time allowed for the final sync of all databases on shutdown (0 closes without syncing)")
+	shutdownTimeout := flag.Duration("shutdown-timeout", 30*time.Second, "time allowed for the whole shutdown (final sync and closing every database); after it the clients still closing are logged and the process exits anyway (0 waits forever)")
+	webhookURL := flag.String("webhook-url", "", "URL that receives a JSON POST when a client's replication fails repeatedly or recovers")
+	webhookCheckInterval := flag.Duration("webhook-check-interval", 0, "how often replicas are synced to check each client's S3 health and detect failures for -webhook-url (default 30s with -webhook-url, otherwise disabled; set it to check S3 health without a webhook)")
+	onIDCollision := flag.String("on-id-collision", IDCollisionError, "when two files share a client id: error (replicate only the first) or suffix (append the parent directory name to the second one's id and replica path)")
+	scanConcurrency := flag.Int("scan-concurrency", 8, "databases registered in parallel during directory scans")
+	maxDBSize := flag.Int64("max-db-size", 0, "database files larger than this many bytes are skipped instead of replicated, to avoid uploading a huge misplaced file (0 = unlimited)")
+	maxClients := flag.Int("max-clients", 0, "maximum number of databases replicated at once; further files are rejected until a slot frees (0 = unlimited)")
+	rescanInterval := flag.Duration("rescan-interval", 5*time.Minute, "how often watch dirs are rescanned to pick up changes missed by the file watcher (0 disables)")
+	registerDebounce := flag.Duration("register-debounce", 2*time.Second, "quiet period after the last write before a new database is registered (0 registers immediately)")
+	eventCoalesceWindow := flag.Duration("event-coalesce-window", 250*time.Millisecond, "write events for the same file within this window are handled once (0 handles every event)")
+	dbExtensions := flag.String("db-extensions", DefaultDBExtensions, "comma-separated file extensions treated as databases (case-insensitive)")
+	watchGlob := flag.String("watch-glob", "", "comma-separated glob patterns for database file names, e.g. tenant-*.db (combined with -db-extensions)")
+	dbNoExtension := flag.Bool("db-no-extension", false, "also treat files without an extension as databases")
+	idStrategy := flag.String("id-strategy", IDStrategyGUID, "how the client id is taken from the filename: guid, filename or regex")
+	idPattern := flag.String("id-pattern", "", "regular expression with a capture group for the client id (used with -id-strategy regex)")
+	debug := flag.Bool("debug", false, "log debug messages (e.g. files skipped by -id-strategy)")
+	timeFormatName := flag.String("time-format", TimeFormatLocal, "how timestamps are shown on the dashboard and in API responses: local (2006-01-02 15:04:05, server time zone), rfc3339 (UTC) or unix (seconds)")
+	litestreamLogLevel := flag.String("litestream-log-level", "info", "minimum level of Litestream messages: debug, info, warn or error; Litestream v0.3 does not tag its lines, so lines reporting an error count as error and all others as info (warnings cannot be told apart: warn keeps only errors)")
+	
+
+	
+	flag.Parse()
+	
+	if *showVersion {
+		fmt.Println(versionInfo())
+		return nil
+	}
+	
+	// Valores do arquivo de configuração preenchem as flags não informadas
+	if *configPath != "" {
+		if err := applyConfigFile(flag.CommandLine, *configPath); err != nil {
+			return err
+		}
+	}
+	
+	// Set address based on host and port flags (-port 0 disables the server)
+	*noServer = *noServer || *port == "0"
+	addr := net.JoinHostPort(*host, *port)
+	if _, err := net.ResolveTCPAddr("tcp", addr); err != nil && !*noServer {
+		return fmt.Errorf("invalid status server address %q (-host %q, -port %q): %w", addr, *host, *port, err)
+	}
+
+	// Validate required parameters
+	var buckets []string
+	for _, name := range strings.Split(*bucket, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			buckets = append(buckets, name)
+		}
+	}
+	if len(buckets) == 0 {
+		flag.Usage()
+		return fmt.Errorf("required: -bucket NAME")
+	}
+	
+	if strings.Trim(*watchDir, ", ") == "" {
+		flag.Usage()
+		return fmt.Errorf("required: -watch-dir PATH")
+	}
+
+	dirBuckets, err := parseBucketMap(*bucketMap)
+	if err != nil {
+		return err
+	}
+
+	replicaConfig, err := backend.config(buckets, dirBuckets)
+	if err != nil {
+		return err
+	}
+
+	debugLogging = *debug
+
+	tmpl, err := parsePathTemplate(*pathTemplate)
+	if err != nil {
+		return err
+	}
+
+	strategy, err := parseIDStrategy(*idStrategy, *idPattern)
+	if err != nil {
+		return err
+	}
+
+	extensions, err := parseDBExtensions(*dbExtensions)
+	if err != nil {
+		return err
+	}
+
+	globs, err := parseWatchGlobs(*watchGlob)
+	if err != nil {
+		return err
+	}
+
+	lsLogLevel, err := parseLogLevel(*litestreamLogLevel)
+	if err != nil {
+		return fmt.Errorf("invalid -litestream-log-level: %w", err)
+	}
+
+	if timeFormat, err = parseTimeFormat(*timeFormatName); err != nil {
+		return err
+	}
+
+	if *retention <= 0 {
+		return fmt.Errorf("invalid -retention %s: must be greater than zero", *retention)
+	}
+	if *retentionCheckInterval <= 0 {
+		return fmt.Errorf("invalid -retention-check-interval %s: must be greater than zero", *retentionCheckInterval)
+	}
+	if *snapshotInterval < 0 {
+		return fmt.Errorf("invalid -snapshot-interval %s: must not be negative", *snapshotInterval)
+	}
+	if *shutdownSyncTimeout < 0 {
+		return fmt.Errorf("invalid -shutdown-sync-timeout %s: must not be negative", *shutdownSyncTimeout)
+	}
+	if *shutdownTimeout < 0 {
+		return fmt.Errorf("invalid -shutdown-timeout %s: must not be negative", *shutdownTimeout)
+	} else if *shutdownTimeout > 0 && *shutdownTimeout <= *shutdownSyncTimeout {
+		return fmt.Errorf("invalid -shutdown-timeout %s: must be longer than -shutdown-sync-timeout (%s)", *shutdownTimeout, *shutdownSyncTimeout)
+	}
+	if *webhookCheckInterval < 0 {
+		return fmt.Errorf("invalid -webhook-check-interval %s: must not be negative", *webhookCheckInterval)
+	} else if *webhookURL != "" && *webhookCheckInterval == 0 {
+		*webhookCheckInterval = defaultHealthCheckInterval
+	}
+	var certs *certReloader
+	if (*tlsCert == "") != (*tlsKey == "") {
+		return fmt.Errorf("required: both -tls-cert and -tls-key to enable TLS")
+	} else if *tlsCert != "" {
+		if certs, err = newCertReloader(*tlsCert, *tlsKey); err != nil {
+			return err
+		}
+	}
+	if *protectDashboard && *authToken == "" {
+		return fmt.Errorf("required: -auth-token TOKEN when -protect-dashboard is set")
+	}
+	if *onIDCollision != IDCollisionError && *onIDCollision != IDCollisionSuffix {
+		return fmt.Errorf("invalid -on-id-collision %q: must be error or suffix", *onIDCollision)
+	}
+	if *scanConcurrency < 1 {
+		return fmt.Errorf("invalid -scan-concurrency %d: must be at least 1", *scanConcurrency)
+	}
+	if *maxClients < 0 {
+		return fmt.Errorf("invalid -max-clients %d: must not be negative", *maxClients)
+	}
+	if *maxDBSize < 0 {
+		return fmt.Errorf("invalid -max-db-size %d: must not be negative", *maxDBSize)
+	}
+	if *dashboardTemplate != "" {
+		if _, err := os.Stat(*dashboardTemplate); err != nil {
+			return fmt.Errorf("invalid -template: %w", err)
+		}
+	}
+	if *dashboardRefresh < 0 {
+		return fmt.Errorf("invalid -dashboard-refresh %s: must not be negative", *dashboardRefresh)
+	}
+	if *rescanInterval < 0 {
+		return fmt.Errorf("invalid -rescan-interval %s: must not be negative", *rescanInterval)
+	}
+	if *eventCoalesceWindow < 0 {
+		return fmt.Errorf("invalid -event-coalesce-window %s: must not be negative", *eventCoalesceWindow)
+	}
+	if err := checkCompression(*compress); err != nil {
+		return err
+	}
+	if err := checkS3Tags(*s3Tags); err != nil {
+		return err
+	}
+	if err := checkS3ObjectSecurity(*s3SSE, *s3SSEKMSKeyID, *s3ACL); err != nil {
+		return err
+	}
+	if err := checkMetaDir(*metaDir); err != nil {
+		return err
+	}
+	if *syncInterval <= 0 {
+		return fmt.Errorf("invalid -sync-interval %s: must be greater than zero", *syncInterval)
+	}
+	if *statusPollInterval < 0 {
+		return fmt.Errorf("invalid -status-poll-interval %s: must not be negative", *statusPollInterval)
+	}
+	if *maxConcurrentRestores < 1 {
+		return fmt.Errorf("invalid -max-concurrent-restores %d: must be at least 1", *maxConcurrentRestores)
+	}
+	if *openBusyTimeout < 0 {
+		return fmt.Errorf("invalid -open-busy-timeout %s: must not be negative", *openBusyTimeout)
+	}
+	if *restoreTimeout < 0 {
+		return fmt.Errorf("invalid -restore-timeout %s: must not be negative", *restoreTimeout)
+	}
+	if *restoreOnCreate && *noRestore {
+		return fmt.Errorf("-restore-on-create cannot be used with -no-restore")
+	}
+	if *once && *selfTest {
+		return fmt.Errorf("-self-test cannot be used with -once: the test database is picked up by the file watcher")
+	}
+	if *selfTest && *dryRun {
+		return fmt.Errorf("-self-test cannot be used with -dry-run: nothing is replicated")
+	}
+
+	var fallbackAddr string
+	if *fallbackPort != "" && !*noServer {
+		fallbackAddr = net.JoinHostPort(*host, *fallbackPort)
+		if _, err := net.ResolveTCPAddr("tcp", fallbackAddr); err != nil {
+			return fmt.Errorf("invalid fallback address %q (-host %q, -fallback-port %q): %w", fallbackAddr, *host, *fallbackPort, err)
+		}
+	}
+
+	config := Config{
+		Buckets:      buckets,
+		BucketMap:    dirBuckets,
+		Addr:         addr,
+		FallbackAddr: fallbackAddr,
+		AuthToken:    *authToken,
+		CORSOrigins:  corsOrigins,
+		ProtectDash:  *protectDashboard,
+		TLS:          certs,
+		DryRun:       *dryRun,
+		NoRestore:    *noRestore,
+		SelfTest:     *selfTest,
+		Once:         *once,
+		Recursive:    *recursive,
+		WaitForDirs:  *waitForDirs,
+
+		RestoreOnCreate: *restoreOnCreate,
+		RestoreTimeout:  *restoreTimeout,
+		OpenBusyTimeout: *openBusyTimeout,
+
+		DashboardRefresh: *dashboardRefresh,
+		NoServer:         *noServer,
+
+		StatusPollInterval: *statusPollInterval,
+
+		DashboardTemplate: *dashboardTemplate,
+
+		MetricsPerClient: *metricsPerClient,
+
+		RegisterDebounce:    *registerDebounce,
+		EventCoalesceWindow: *eventCoalesceWindow,
+		MaxLagBytes:         *maxLagBytes,
+		RegisterMaxRetries:  *registerMaxRetries,
+		ScanConcurrency:     *scanConcurrency,
+		MaxClients:          *maxClients,
+		MaxDBSize:           *maxDBSize,
+		RescanInterval:      *rescanInterval,
+
+		ShutdownSyncTimeout: *shutdownSyncTimeout,
+		ShutdownTimeout:     *shutdownTimeout,
+
+		MaxConcurrentRestores: *maxConcurrentRestores,
+		RestoreDir:            *restoreDir,
+
+		WebhookURL:           *webhookURL,
+		WebhookCheckInterval: *webhookCheckInterval,
+
+		Retention:              *retention,
+		RetentionCheckInterval: *retentionCheckInterval,
+		SnapshotInterval:       *snapshotInterval,
+		SyncInterval:           *syncInterval,
+
+		DBExtensions:  extensions,
+		DBNoExtension: *dbNoExtension,
+		WatchGlobs:    globs,
+
+		IDStrategy:         strategy,
+		OnIDCollision:      *onIDCollision,
+		LitestreamLogLevel: lsLogLevel,
+
+		PathTemplate: tmpl,
+		Env:          *env,
+
+		ReplicaType: replicaConfig.ReplicaType,
+		S3:          replicaConfig.S3,
+		ABS:         replicaConfig.ABS,
+	}
+
+	// Run directory watching mode
+	if *auditLogPath != "" {
+		audit, err := openAuditLog(*auditLogPath)
+		if err != nil {
+			return err
+		}
+		defer audit.Close()
+		config.Audit = audit
+	}
+
+	if !*skipBucketCheck {
+		if err := config.checkBuckets(ctx, allBuckets(config.Buckets, config.BucketMap)); err != nil {
+			return err
+		}
+	}
+
+	return runDirectoryMode(ctx, *watchDir, config)
+}
+
+// applyConfigFile carrega um arquivo JSON/YAML cujas chaves são nomes de flags
+// (ex: "bucket", "watch-dir", "retention") e aplica os valores às flags que
+// não foram passadas na linha de comando. Listas viram valores separados por vírgula.
+func applyConfigFile(fs *flag.FlagSet, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	var values map[string]interface{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &values)
+	default:
+		err = json.Unmarshal(data, &values)
+	}
+	if err != nil {
+		return fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+
+	// Flags passadas explicitamente têm precedência sobre o arquivo
+	explicit := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) {
+		explicit[f.Name] = true
+	})
+
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if name == "config" || fs.Lookup(name) == nil {
+			return fmt.Errorf("invalid config file %s: unknown field %q", path, name)
+		}
+		if explicit[name] {
+			continue
+		}
+
+		value, err := configValue(values[name])
+		if err != nil {
+			return fmt.Errorf("invalid config file %s: field %q: %v", path, name, err)
+		}
+		if err := fs.Set(name, value); err != nil {
+			return fmt.Errorf("invalid config file %s: field %q: %v", path, name, err)
+		}
+	}
+
+	return nil
+}
+
+// stringList flag que pode ser repetida; cada valor também aceita uma lista
+// separada por vírgulas (formato das listas do arquivo de configuração)
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			*l = append(*l, item)
+		}
+	}
+	return nil
+}
+
+// configValue converte um valor do arquivo de configuração para o formato da flag
+func configValue(v interface{}) (string, error) {
+	switch v := v.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case int:
+		return strconv.Itoa(v), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case []interface{}:
+		items := make([]string, 0, len(v))
+		for _, item := range v {
+			s, err := configValue(item)
+			if err != nil {
+				return "", err
+			}
+			items = append(items, s)
+		}
+		return strings.Join(items, ","), nil
+	default:
+		return "", fmt.Errorf("unsupported value type %T", v)
+	}
+}
+
+// runDirectoryMode runs the new multi-database directory watching mode
+func runDirectoryMode(ctx context.Context, watchDirStr string, config Config) error {
+	watchDirs, err := resolveWatchDirs(watchDirStr)
+	if err != nil {
+		return err
+	}
+	config.WatchDirs = watchDirs
+
+	if config.BucketMap, err = resolveBucketMap(config.BucketMap, watchDirs); err != nil {
+		return err
+	}
+	if config.RestoreDir, err = resolveRestoreDir(config.RestoreDir, watchDirs); err != nil {
+		return err
+	}
+
+	fmt.Println("🏢 Litestream Multi-Client Manager")
+	fmt.Println("===============================================")
+	fmt.Printf("🏷️  Version: %s\n", versionInfo())
+	fmt.Printf("🗄️  Replica Type: %s\n", config.ReplicaType)
+	fmt.Printf("📦 Buckets: %s\n", strings.Join(config.Buckets, ", "))
+	for _, dir := range watchDirs {
+		if bucket, exists := config.BucketMap[dir]; exists {
+			fmt.Printf("📦 Bucket for %s: %s\n", dir, bucket)
+		}
+	}
+	if config.S3.Endpoint != "" {
+		fmt.Printf("🔗 S3 Endpoint: %s\n", config.S3.Endpoint)
+	}
+	fmt.Printf("👀 Watching Directories: %v\n", watchDirs)
+	if config.Recursive {
+		fmt.Println("🌳 Recursive watching: enabled")
+	}
+	if config.DryRun {
+		fmt.Println("🧪 Dry run: databases are detected but NOT replicated")
+	}
+	if config.NoRestore {
+		fmt.Println("🚫 Restore disabled: local databases are opened as is and never restored from S3")
+	}
+	scheme := "http"
+	if config.TLS != nil {
+		scheme = "https"
+	}
+	displayAddr := config.Addr
+	if host, port, err := net.SplitHostPort(config.Addr); err == nil && host == "" {
+		displayAddr = net.JoinHostPort("localhost", port)
+	}
+	if config.Once {
+		fmt.Println("🏁 Once: scan, sync to S3 and exit")
+	} else if config.NoServer {
+		fmt.Println("🌐 Status Server: disabled")
+	} else {
+		fmt.Printf("🌐 Status Server: %s://%s\n", scheme, displayAddr)
+	}
+	fmt.Println()
+
+	// Create and start database manager
+	dm := NewDatabaseManager(ctx, config)
+	defer dm.stopWithTimeout(config.ShutdownTimeout)
+
+	if config.Once {
+		return dm.runOnce()
+	}
+
+	if err := dm.Start(); err != nil {
+		return fmt.Errorf("failed to start database manager: %w", err)
+	}
+
+	if config.SelfTest {
+		if err := dm.runSelfTest(ctx); err != nil {
+			log.Printf("❌ Self-test FAILED: %v", err)
+			return fmt.Errorf("self-test failed: %w", err)
+		}
+		log.Printf("✅ Self-test passed")
+	}
+
+	prometheus.MustRegister(newMetricsCollector(dm))
+
+	// Start status web server
+	// O dashboard é secundário: se não subir, a replicação continua sem ele
+	var server *http.Server
+	if config.NoServer {
+		log.Printf("🔇 Status server disabled (-no-server): no dashboard, API or metrics endpoint")
+	} else if ln, err := listenStatus(config.Addr, config.FallbackAddr); err != nil {
+		log.Printf("⚠️  Status server disabled, replication continues: %v", err)
+	} else if server, err = startStatusServer(dm, ln); err != nil {
+		ln.Close()
+		log.Printf("⚠️  Status server disabled, replication continues: %v", err)
+	}
+
+	// systemd (Type=notify): pronto após o scan inicial e com o servidor escutando
+	if err := sdNotify(fmt.Sprintf("READY=1\nSTATUS=Replicating %d clients", dm.activeClientCount())); err != nil {
+		log.Printf("⚠️  Failed to notify systemd: %v", err)
+	}
+	if interval := sdWatchdogInterval(); interval > 0 {
+		go dm.watchdog(interval)
+	}
+
+	// Wait for signal
+	<-ctx.Done()
+	log.Print("litestream manager received signal, shutting down")
+	sdNotify("STOPPING=1")
+
+	// Encerra o servidor HTTP antes de parar a replicação, aguardando requisições em andamento
+	if server != nil {
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			log.Printf("⚠️  Status server shutdown: %v", err)
+		}
+	}
+	return nil
+}
+
+// sdNotify envia o estado ao systemd pelo protocolo NOTIFY_SOCKET (sd_notify);
+// sem a variável (fora do systemd ou Type diferente de notify) não faz nada
+func sdNotify(state string) error {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return nil
+	}
+	if socket[0] == '@' {
+		socket = "\x00" + socket[1:] // socket abstrato do Linux
+	}
+
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = conn.Write([]byte(state))
+	return err
+}
+
+// sdWatchdogInterval intervalo dos pings WATCHDOG=1: metade de WATCHDOG_USEC,
+// como recomenda o systemd (0 quando o watchdog não está habilitado para este processo)
+func sdWatchdogInterval() time.Duration {
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 {
+		return 0
+	}
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return 0
+	}
+	return time.Duration(usec) * time.Microsecond / 2
+}
+
+// watchdog envia WATCHDOG=1 enquanto o gerenciador responde. O ping passa pelo
+// mutex principal, então um travamento dele faz o systemd reiniciar o serviço.
+func (dm *DatabaseManager) watchdog(interval time.Duration) {
+	log.Printf("🐕 systemd watchdog enabled: ping every %s", interval)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-dm.ctx.Done():
+			return
+		case <-ticker.C:
+			dm.activeClientCount()
+			if err := sdNotify("WATCHDOG=1"); err != nil {
+				log.Printf("⚠️  Failed to ping systemd watchdog: %v", err)
+			}
+		}
+	}
+}
+
+// activeClientCount quantidade de clientes replicando
+func (dm *DatabaseManager) activeClientCount() int {
+	dm.mutex.RLock()
+	defer dm.mutex.RUnlock()
+	return len(dm.databases)
+}
+
+// resolveWatchDirs converte a lista de -watch-dir em caminhos absolutos sem
+// symlinks (o diretório de trabalho do processo deixa de importar, ex: systemd),
+// remove duplicados e recusa diretórios aninhados, cujos bancos seriam
+// registrados duas vezes. Entradas vazias (vírgula no fim ou dobrada,
+// ex: "/data,") são descartadas.
+func resolveWatchDirs(list string) ([]string, error) {
+	var dirs []string
+	seen := make(map[string]string)
+	for _, dir := range strings.Split(list, ",") {
+		dir = strings.TrimSpace(dir)
+		if dir == "" {
+			continue
+		}
+
+		resolved, err := resolveDir(dir)
+		if err != nil {
+			return nil, fmt.Errorf("invalid -watch-dir %q: %w", dir, err)
+		}
+
+		if original, exists := seen[resolved]; exists {
+			log.Printf("⚠️  Watch dir %s is the same as %s, watching it once", dir, original)
+			continue
+		}
+		if resolved != dir {
+			log.Printf("📂 Watch dir %s resolved to %s", dir, resolved)
+		}
+		seen[resolved] = dir
+		dirs = append(dirs, resolved)
+	}
+	if len(dirs) == 0 {
+		return nil, fmt.Errorf("invalid -watch-dir %q: no directory in the list", list)
+	}
+
+	// A varredura sempre desce nos subdiretórios: um watch dir dentro de outro
+	// teria os bancos registrados duas vezes, com ou sem -recursive
+	for _, a := range dirs {
+		for _, b := range dirs {
+			if a != b && strings.HasPrefix(b, strings.TrimSuffix(a, string(filepath.Separator))+string(filepath.Separator)) {
+				return nil, fmt.Errorf("invalid -watch-dir: %s is inside %s, and its databases would be registered twice", b, a)
+			}
+		}
+	}
+	return dirs, nil
+}
+
+// resolveDir caminho absoluto do diretório com os links simbólicos resolvidos;
+// diretórios ainda inexistentes (-wait-for-dirs) ficam só com o caminho absoluto
+func resolveDir(dir string) (string, error) {
+	resolved, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	if real, err := filepath.EvalSymlinks(resolved); err == nil {
+		resolved = real
+	} else if !os.IsNotExist(err) {
+		return "", err
+	}
+	return resolved, nil
+}
+
+// resolveRestoreDir resolve -restore-dir (vazio = diretório temporário); ele não
+// pode ficar dentro de um watch dir, senão um restore viraria um cliente novo
+func resolveRestoreDir(dir string, watchDirs []string) (string, error) {
+	if dir == "" {
+		dir = os.TempDir()
+	}
+	resolved, err := resolveDir(dir)
+	if err != nil {
+		return "", fmt.Errorf("invalid -restore-dir %s: %w", dir, err)
+	}
+	for _, watchDir := range watchDirs {
+		if isWithinDir(watchDir, resolved) {
+			return "", fmt.Errorf("invalid -restore-dir %s: must not be inside watch dir %s", dir, watchDir)
+		}
+	}
+	return resolved, nil
+}
+
+// isWithinDir informa se path é dir ou está dentro dele (ambos já limpos)
+func isWithinDir(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// parseBucketMap lê -map ("dir=bucket,dir=bucket"); os diretórios são
+// resolvidos depois, junto com os watch dirs
+func parseBucketMap(s string) (map[string]string, error) {
+	m := make(map[string]string)
+	for _, entry := range strings.Split(s, ",") {
+		if entry = strings.TrimSpace(entry); entry == "" {
+			continue
+		}
+		i := strings.LastIndex(entry, "=")
+		if i < 0 {
+			return nil, fmt.Errorf("invalid -map entry %q: expected dir=bucket", entry)
+		}
+		dir, bucket := strings.TrimSpace(entry[:i]), strings.TrimSpace(entry[i+1:])
+		if dir == "" || bucket == "" {
+			return nil, fmt.Errorf("invalid -map entry %q: expected dir=bucket", entry)
+		}
+		if _, exists := m[dir]; exists {
+			return nil, fmt.Errorf("invalid -map: %s is mapped more than once", dir)
+		}
+		m[dir] = bucket
+	}
+	return m, nil
+}
+
+// resolveBucketMap troca os diretórios de -map pelos watch dirs resolvidos,
+// recusando diretórios que não estão em -watch-dir (provável erro de digitação)
+func resolveBucketMap(m map[string]string, watchDirs []string) (map[string]string, error) {
+	watched := make(map[string]bool, len(watchDirs))
+	for _, dir := range watchDirs {
+		watched[dir] = true
+	}
+
+	resolved := make(map[string]string, len(m))
+	for dir, bucket := range m {
+		path, err := resolveDir(dir)
+		if err != nil {
+			return nil, fmt.Errorf("invalid -map dir %q: %w", dir, err)
+		}
+		if !watched[path] {
+			return nil, fmt.Errorf("invalid -map dir %q: not one of the -watch-dir directories", dir)
+		}
+		if other, exists := resolved[path]; exists && other != bucket {
+			return nil, fmt.Errorf("invalid -map: %s is mapped to both %s and %s", path, other, bucket)
+		}
+		resolved[path] = bucket
+	}
+	return resolved, nil
+}
+
+// allBuckets buckets globais mais os de -map, sem repetições
+func allBuckets(buckets []string, m map[string]string) []string {
+	all := append([]string(nil), buckets...)
+	seen := make(map[string]bool, len(buckets))
+	for _, bucket := range buckets {
+		seen[bucket] = true
+	}
+	for _, bucket := range m {
+		if !seen[bucket] {
+			seen[bucket] = true
+			all = append(all, bucket)
+		}
+	}
+	sort.Strings(all[len(buckets):])
+	return all
+}
+
+// bucketsFor buckets de destino do banco: o de -map do watch dir que o contém
+// (o mais interno, se houver mais de um) ou os de -bucket
+func (dm *DatabaseManager) bucketsFor(dbPath string) []string {
+	var match, bucket string
+	for dir, b := range dm.config.BucketMap {
+		prefix := strings.TrimSuffix(dir, string(filepath.Separator)) + string(filepath.Separator)
+		if strings.HasPrefix(dbPath, prefix) && len(dir) > len(match) {
+			match, bucket = dir, b
+		}
+	}
+	if match == "" {
+		return dm.buckets
+	}
+	return []string{bucket}
+}
+
+// listenStatus abre o socket do servidor de status, tentando o endereço
+// alternativo quando o principal já estiver em uso
+func listenStatus(addr, fallbackAddr string) (net.Listener, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err == nil || fallbackAddr == "" {
+		return ln, err
+	}
+
+	log.Printf("⚠️  Cannot listen on %s (%v), trying fallback %s", addr, err, fallbackAddr)
+	ln, fallbackErr := net.Listen("tcp", fallbackAddr)
+	if fallbackErr != nil {
+		return nil, fmt.Errorf("%v; fallback: %w", err, fallbackErr)
+	}
+	log.Printf("🌐 Status Server listening on fallback address %s", fallbackAddr)
+	return ln, nil
+}
+
+
+
+// extractClientID extracts GUID from database filename for S3 organization
+// Expected format: /data/12345678-1234-5678-9abc-123456789012.db
+// (or {12345678-1234-5678-9abc-123456789012}.db; the braces are not part of the ID)
+func extractClientID(dbPath string) string {
+	// Extract filename from path
+	base := filepath.Base(dbPath)
+	guid := strings.TrimSuffix(base, filepath.Ext(base))
+	if len(guid) == 38 && guid[0] == '{' && guid[37] == '}' {
+		guid = guid[1:37]
+	}
+	
+	// Validate GUID format; o ID é normalizado em minúsculas para que
+	// ABCD...db e abcd...db sejam o mesmo cliente (e o mesmo caminho no S3)
+	if isValidGUID(guid) {
+		return strings.ToLower(guid)
+	}
+	
+	// Return empty string for invalid GUIDs - will be ignored
+	return ""
+}
+
+// Estratégias de extração do clientID (-id-strategy)
+const (
+	IDStrategyGUID     = "guid"     // nome do arquivo deve ser um GUID
+	IDStrategyFilename = "filename" // nome do arquivo inteiro (sem extensão)
+	IDStrategyRegex    = "regex"    // primeiro grupo de captura de -id-pattern
+)
+
+// ClientIDStrategy define como o clientID é extraído do nome do arquivo
+type ClientIDStrategy struct {
+	Name    string         // guid, filename ou regex (vazio = guid)
+	Pattern *regexp.Regexp // usado apenas pela estratégia regex
+}
+
+// parseIDStrategy valida -id-strategy e -id-pattern
+func parseIDStrategy(name, pattern string) (ClientIDStrategy, error) {
+	switch name {
+	case IDStrategyGUID, IDStrategyFilename:
+		return ClientIDStrategy{Name: name}, nil
+	case IDStrategyRegex:
+		if pattern == "" {
+			return ClientIDStrategy{}, fmt.Errorf("required: -id-pattern REGEX when -id-strategy is regex")
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return ClientIDStrategy{}, fmt.Errorf("invalid -id-pattern: %w", err)
+		}
+		if re.NumSubexp() < 1 {
+			return ClientIDStrategy{}, fmt.Errorf("invalid -id-pattern %q: a capture group is required", pattern)
+		}
+		return ClientIDStrategy{Name: name, Pattern: re}, nil
+	default:
+		return ClientIDStrategy{}, fmt.Errorf("invalid -id-strategy %q: must be guid, filename or regex", name)
+	}
+}
+
+// Extract retorna o clientID do arquivo ou string vazia se o nome não é aceito
+func (s ClientIDStrategy) Extract(dbPath string) string {
+	switch s.Name {
+	case IDStrategyFilename:
+		base := filepath.Base(dbPath)
+		return strings.TrimSuffix(base, filepath.Ext(base))
+	case IDStrategyRegex:
+		base := filepath.Base(dbPath)
+		m := s.Pattern.FindStringSubmatch(strings.TrimSuffix(base, filepath.Ext(base)))
+		if m == nil {
+			return ""
+		}
+		return m[1]
+	default:
+		return extractClientID(dbPath)
+	}
+}
+
+// String nome da estratégia para logs
+func (s ClientIDStrategy) String() string {
+	if s.Name == "" {
+		return IDStrategyGUID
+	}
+	return s.Name
+}
+
+// isValidGUID validates if string follows GUID pattern
+func isValidGUID(s string) bool {
+	// Basic GUID validation: xxxxxxxx-xxxx-xxxx-xxxx-xxxxxxxxxxxx
+	if len(s) != 36 {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case i == 8 || i == 13 || i == 18 || i == 23:
+			if c != '-' {
+				return false
+			}
+		case '0' <= c && c <= '9', 'a' <= c && c <= 'f', 'A' <= c && c <= 'F':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// NewDatabaseManager cria novo gerenciador otimizado (1:1 cliente:banco).
+// O cancelamento de ctx (ex: sinal) interrompe as operações em andamento no S3.
+func NewDatabaseManager(ctx context.Context, config Config) *DatabaseManager {
+	ctx, cancel := context.WithCancel(ctx)
+	
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		log.Fatal("Failed to create file watcher:", err)
+	}
+
+	dm := &DatabaseManager{
+		databases:    make(map[string]*litestream.DB), // clientID -> DB
+		clients:      make(map[string]*ClientConfig),  // clientID -> config
+		pathIndex:    make(map[string]string),         // path -> clientID
+		watchedDirs:  make(map[string]struct{}),       // dir -> watch ativo
+		pending:      make(map[string]*time.Timer),    // path -> timer de registro
+		retries:      make(map[string]*retryState),    // path -> retry agendado
+		failed:       make(map[string]*FailedRegistration),
+		waitingDirs:  make(map[string]struct{}),
+		opening:      make(map[string]string),
+		collisions:   make(map[string]*IDCollision),
+		rejected:     make(map[string]*RejectedRegistration),
+		skipped:      make(map[string]*SkippedFile),
+		problems:     make(map[string]*RegistrationProblem),
+		diskUsage:    make(map[string]int64),
+		degradedDirs: make(map[string]*DegradedDir),
+		paused:       make(map[string]bool),
+		syncing:      make(map[string]bool),
+		restoring:    make(map[string]bool),
+		released:     make(map[string]string),
+		rescanNow:    make(chan struct{}, 1),
+		dirScans:     make(map[string]bool),
+		dirScanNow:   make(chan struct{}, 1),
+		restoreSlots: make(chan struct{}, config.MaxConcurrentRestores),
+		events:       newEventBus(),
+		progress:     make(map[string]*replicaProgress),
+		stats:        make(map[string]*clientStats),
+		syncFailures: make(map[string]*syncFailureState),
+		closing:      make(map[string]bool),
+		watcher:      watcher,
+		config:       config,
+		bucket:       config.Buckets[0],
+		buckets:      config.Buckets,
+		watchDirs:    config.WatchDirs,
+		ctx:          ctx,
+		cancel:       cancel,
+	}
+	dm.replicaClients = &dm.config
+	return dm
+}
+
+// Start inicia o monitoramento de diretórios
+func (dm *DatabaseManager) Start() error {
+	// Adiciona diretórios para monitoramento
+	for _, dir := range dm.watchDirs {
+		if dm.config.WaitForDirs {
+			if _, err := os.Stat(dir); os.IsNotExist(err) {
+				log.Printf("⏳ Directory does not exist yet, waiting for it: %s", dir)
+				dm.mutex.Lock()
+				dm.waitingDirs[dir] = struct{}{}
+				dm.mutex.Unlock()
+				continue
+			}
+		}
+		
+		if err := dm.addWatchDir(dir); err != nil {
+			log.Printf("❌ Failed to watch directory %s: %v", dir, err)
+			continue
+		}
+		log.Printf("👀 Watching directory: %s", dir)
+	}
+
+	// Inicia goroutine de monitoramento
+	go dm.watchFiles()
+	go dm.processRetries()
+	go dm.pollWaitingDirs()
+	go dm.monitorWatchDirs()
+	go dm.trackDiskUsage()
+	if dm.config.StatusPollInterval > 0 {
+		go dm.pollStatus()
+	}
+	go dm.trackReplicaProgress()
+	go dm.rescanLoop()
+	if dm.config.WebhookCheckInterval > 0 {
+		go dm.monitorReplication()
+	}
+	
+	// Escaneia arquivos existentes
+	return dm.scanExistingDatabases()
+}
+
+// selfTestTimeout tempo máximo do -self-test (registro, replicação e leitura)
+const selfTestTimeout = 2 * time.Minute
+
+// selfTestTable tabela com a linha canário do banco de teste
+const selfTestTable = "litestream_manager_canary"
+
+// runSelfTest (-self-test) cria um banco GUID temporário com uma linha canário
+// no primeiro watch dir, espera o registro e a replicação, confere a geração em
+// cada bucket, relê a linha de uma cópia restaurada e remove tudo ao final
+func (dm *DatabaseManager) runSelfTest(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, selfTestTimeout)
+	defer cancel()
+
+	if len(dm.watchDirs) == 0 {
+		return fmt.Errorf("no watch directory")
+	}
+	dir := dm.watchDirs[0]
+	if _, err := os.Stat(dir); err != nil {
+		return fmt.Errorf("watch directory unavailable: %w", err)
+	}
+
+	guid, err := newGUID()
+	if err != nil {
+		return err
+	}
+	// .db quando aceito; senão a primeira (em ordem) de -db-extensions, ou nenhuma
+	ext := ".db"
+	if !dm.config.DBExtensions[ext] {
+		exts := make([]string, 0, len(dm.config.DBExtensions))
+		for e := range dm.config.DBExtensions {
+			exts = append(exts, e)
+		}
+		sort.Strings(exts)
+		ext = ""
+		if len(exts) > 0 {
+			ext = exts[0]
+		}
+	}
+	dbPath := filepath.Join(dir, guid+ext)
+	// Um nome que o watcher ignora só falharia ao fim de selfTestTimeout
+	if !dm.isDatabaseFile(dbPath) {
+		return fmt.Errorf("test database name %s is ignored by the watcher: check -db-extensions, -db-no-extension and -watch-glob", filepath.Base(dbPath))
+	}
+	clientID := dm.extractClientID(dbPath)
+	if clientID == "" {
+		return fmt.Errorf("test database name %s does not match -id-strategy %s", filepath.Base(dbPath), dm.config.IDStrategy)
+	}
+	log.Printf("🧪 Self-test: creating %s", dbPath)
+
+	// Criado com outro nome e renomeado, para o watcher só ver o banco pronto
+	tmpPath := dbPath + ".tmp"
+	if err := writeCanary(ctx, tmpPath, guid); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("cannot create test database: %w", err)
+	}
+
+	var clients []litestream.ReplicaClient
+	defer func() {
+		dm.unregisterDatabase(dbPath)
+		for _, path := range []string{dbPath, dbPath + "-wal", dbPath + "-shm"} {
+			os.Remove(path)
+		}
+		os.RemoveAll(metaPath(dbPath))
+
+		// Contexto próprio: a limpeza também roda quando o teste estoura o tempo
+		cleanupCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		for _, client := range clients {
+			generations, err := client.Generations(cleanupCtx)
+			for _, generation := range generations {
+				if err == nil {
+					err = client.DeleteGeneration(cleanupCtx, generation)
+				}
+			}
+			if err != nil {
+				log.Printf("⚠️  Self-test: failed to delete test replica of %s: %v", clientID, err)
+			}
+		}
+	}()
+	if err := os.Rename(tmpPath, dbPath); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+
+	// Aguarda o watcher registrar o banco
+	ticker := time.NewTicker(200 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		dm.mutex.RLock()
+		lsdb, registered := dm.databases[clientID]
+		dm.mutex.RUnlock()
+		if registered {
+			for _, replica := range lsdb.Replicas {
+				clients = append(clients, replica.Client)
+			}
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("test database was not registered within %s (see the log above)", selfTestTimeout)
+		case <-ticker.C:
+		}
+	}
+
+	if _, err := dm.syncClient(ctx, clientID); err != nil {
+		return err
+	}
+	for _, client := range clients {
+		generations, err := client.Generations(ctx)
+		if err != nil {
+			return fmt.Errorf("cannot list generations in %s: %w", replicaClientURL(client), err)
+		} else if len(generations) == 0 {
+			return fmt.Errorf("no generation found in %s after sync", replicaClientURL(client))
+		}
+		log.Printf("🧪 Self-test: generation %s found in %s", generations[0], replicaClientURL(client))
+	}
+
+	if dm.config.NoRestore {
+		log.Printf("🧪 Self-test: canary read-back skipped (-no-restore)")
+		return nil
+	}
+	result, err := dm.verifyClient(ctx, clientID, selfTestTable)
+	if err != nil {
+		return err
+	} else if !result.OK {
+		return fmt.Errorf("restored test database failed integrity check: %s", result.IntegrityCheck)
+	} else if result.RowCount == nil || *result.RowCount != 1 {
+		return fmt.Errorf("canary row missing from the restored test database")
+	}
+	log.Printf("🧪 Self-test: canary row read back from %s", replicaClientURL(clients[0]))
+	return nil
+}
+
+// writeCanary cria o banco de teste do -self-test com uma linha canário
+func writeCanary(ctx context.Context, path, value string) error {
+	db, err := sql.Open("sqlite3", sqliteURI(path, nil))
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if _, err := db.ExecContext(ctx, fmt.Sprintf(`CREATE TABLE %s (id INTEGER PRIMARY KEY, value TEXT)`, selfTestTable)); err != nil {
+		return err
+	}
+	_, err = db.ExecContext(ctx, fmt.Sprintf(`INSERT INTO %s (id, value) VALUES (1, ?)`, selfTestTable), value)
+	return err
+}
+
+// newGUID gera um GUID aleatório (formato aceito por isValidGUID)
+func newGUID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16]), nil
+}
+
+// waitingDirPollInterval frequência de verificação dos diretórios aguardados
+const waitingDirPollInterval = 5 * time.Second
+
+// pollWaitingDirs passa a monitorar os watch dirs aguardados assim que forem
+// criados, registrando os bancos que já existirem dentro deles
+func (dm *DatabaseManager) pollWaitingDirs() {
+	ticker := time.NewTicker(waitingDirPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-dm.ctx.Done():
+			return
+		case <-ticker.C:
+			for _, dir := range dm.waitingDirList() {
+				if _, err := os.Stat(dir); err != nil {
+					continue
+				}
+				if err := dm.addWatchDir(dir); err != nil {
+					log.Printf("⚠️  Directory %s appeared but cannot be watched yet: %v", dir, err)
+					continue
+				}
+
+				dm.mutex.Lock()
+				delete(dm.waitingDirs, dir)
+				dm.mutex.Unlock()
+
+				log.Printf("👀 Watching directory (now available): %s", dir)
+				dm.scanDirectory(dir)
+			}
+		}
+	}
+}
+
+// rescanLoop repete a varredura a cada -rescan-interval, cobrindo eventos
+// que o fsnotify perdeu (carga alta, sistemas de arquivos de rede), e quando
+// requestRescan pede uma varredura imediata
+func (dm *DatabaseManager) rescanLoop() {
+	var tick <-chan time.Time
+	if dm.config.RescanInterval > 0 {
+		ticker := time.NewTicker(dm.config.RescanInterval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case <-dm.ctx.Done():
+			return
+		case <-tick:
+			dm.rescan()
+		case <-dm.rescanNow:
+			dm.rescan()
+		case <-dm.dirScanNow:
+			dm.scanRequestedDirs()
+		}
+	}
+}
+
+// requestRescan agenda um rescan imediato sem bloquear; pedidos feitos
+// enquanto outro aguarda são agrupados em uma única varredura
+func (dm *DatabaseManager) requestRescan() {
+	select {
+	case dm.rescanNow <- struct{}{}:
+	default:
+	}
+}
+
+// requestDirScan agenda a varredura de um único diretório sem bloquear quem
+// recebe os eventos do fsnotify; o mesmo diretório pedido várias vezes é
+// varrido uma vez só
+func (dm *DatabaseManager) requestDirScan(dir string) {
+	dm.pendingMutex.Lock()
+	dm.dirScans[dir] = true
+	dm.pendingMutex.Unlock()
+
+	select {
+	case dm.dirScanNow <- struct{}{}:
+	default:
+	}
+}
+
+// scanRequestedDirs varre os diretórios pedidos por requestDirScan
+func (dm *DatabaseManager) scanRequestedDirs() {
+	dm.pendingMutex.Lock()
+	dirs := make([]string, 0, len(dm.dirScans))
+	for dir := range dm.dirScans {
+		dirs = append(dirs, dir)
+	}
+	dm.dirScans = make(map[string]bool)
+	dm.pendingMutex.Unlock()
+
+	for _, dir := range dirs {
+		if dm.ctx.Err() != nil {
+			return
+		}
+		dm.scanDirectory(dir)
+	}
+}
+
+// rescan remove os clientes cujos arquivos sumiram e registra os bancos
+// ainda não monitorados
+func (dm *DatabaseManager) rescan() {
+	dm.mutex.RLock()
+	var paths []string
+	for _, config := range dm.clients {
+		paths = append(paths, config.DatabasePath)
+	}
+	// Colisões, recusas e problemas também saem da lista quando o arquivo some
+	for path := range dm.collisions {
+		paths = append(paths, path)
+	}
+	for path := range dm.rejected {
+		paths = append(paths, path)
+	}
+	for path := range dm.skipped {
+		paths = append(paths, path)
+	}
+	for path := range dm.problems {
+		paths = append(paths, path)
+	}
+	var released []string
+	for path := range dm.released {
+		released = append(released, path)
+	}
+	dm.mutex.RUnlock()
+
+	for _, path := range paths {
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			log.Printf("🗑️  Database missing on rescan: %s", path)
+			dm.unregisterDatabase(path)
+		}
+	}
+	for _, path := range released {
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			dm.forgetReleased(path)
+		}
+	}
+
+	waiting := make(map[string]bool)
+	for _, dir := range dm.waitingDirList() {
+		waiting[dir] = true
+	}
+	for _, dir := range dm.watchDirs {
+		if !waiting[dir] {
+			dm.scanDirectory(dir)
+		}
+	}
+}
+
+// watchDirCheckInterval frequência da verificação de acesso aos watch dirs
+const watchDirCheckInterval = 30 * time.Second
+
+// DegradedDir watch dir que deixou de ser acessível depois de monitorado
+type DegradedDir struct {
+	Path  string    `json:"path"`
+	Error string    `json:"error"`
+	Since time.Time `json:"since"`
+}
+
+// monitorWatchDirs verifica periodicamente os watch dirs: o fsnotify para de
+// entregar eventos em silêncio quando um mount some, então o diretório é
+// marcado como degradado e volta a ser monitorado quando reaparecer
+func (dm *DatabaseManager) monitorWatchDirs() {
+	ticker := time.NewTicker(watchDirCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-dm.ctx.Done():
+			return
+		case <-ticker.C:
+			dm.checkWatchDirs()
+		}
+	}
+}
+
+// checkWatchDirs marca os watch dirs inacessíveis e recupera os que voltaram
+func (dm *DatabaseManager) checkWatchDirs() {
+	waiting := make(map[string]bool)
+	for _, dir := range dm.waitingDirList() {
+		waiting[dir] = true
+	}
+
+	for _, dir := range dm.watchDirs {
+		if waiting[dir] {
+			continue // ainda não existia; tratado por pollWaitingDirs
+		}
+
+		err := checkDirAccess(dir)
+
+		dm.mutex.RLock()
+		degraded := dm.degradedDirs[dir]
+		dm.mutex.RUnlock()
+
+		switch {
+		case err != nil && degraded == nil:
+			logEvent(MsgWatchDirUnavailable, dir, err)
+			dm.mutex.Lock()
+			dm.degradedDirs[dir] = &DegradedDir{Path: dir, Error: err.Error(), Since: time.Now()}
+			dm.mutex.Unlock()
+			// O watch do diretório antigo não entrega mais eventos
+			dm.unwatchTree(dir)
+		case err == nil && degraded != nil:
+			if err := dm.addWatchDir(dir); err != nil {
+				log.Printf("⚠️  Directory %s is back but cannot be watched yet: %v", dir, err)
+				continue
+			}
+			dm.mutex.Lock()
+			delete(dm.degradedDirs, dir)
+			dm.mutex.Unlock()
+
+			logEvent(MsgWatchDirRecovered, time.Since(degraded.Since).Round(time.Second), dir)
+			dm.scanDirectory(dir)
+		}
+	}
+}
+
+// checkDirAccess verifica se o caminho existe, é um diretório e pode ser lido
+func checkDirAccess(dir string) error {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("not a directory")
+	}
+	f, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.Readdirnames(1)
+	if err == io.EOF {
+		return nil
+	}
+	return err
+}
+
+// degradedDirList lista os watch dirs degradados ordenados por caminho (chamar com o lock)
+func (dm *DatabaseManager) degradedDirList() []*DegradedDir {
+	dirs := make([]*DegradedDir, 0, len(dm.degradedDirs))
+	for _, dir := range dm.degradedDirs {
+		dirs = append(dirs, dir)
+	}
+	sort.Slice(dirs, func(i, j int) bool {
+		return dirs[i].Path < dirs[j].Path
+	})
+	return dirs
+}
+
+// waitingDirList lista os watch dirs que ainda não existem
+func (dm *DatabaseManager) waitingDirList() []string {
+	dm.mutex.RLock()
+	defer dm.mutex.RUnlock()
+
+	dirs := make([]string, 0, len(dm.waitingDirs))
+	for dir := range dm.waitingDirs {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+	return dirs
+}
+
+// Stop para o gerenciador (1:1 otimizado)
+func (dm *DatabaseManager) Stop() {
+	dm.cancel()
+	dm.watcher.Close()
+	
+	// Descarta registros ainda aguardando o debounce
+	dm.pendingMutex.Lock()
+	for dbPath, timer := range dm.pending {
+		timer.Stop()
+		delete(dm.pending, dbPath)
+	}
+	dm.pendingMutex.Unlock()
+	
+	// Cópia dos bancos sob o lock; o sync final e o SoftClose podem demorar
+	// (rede) e não devem bloquear a API nem os handlers durante o encerramento
+	dm.mutex.RLock()
+	databases := make(map[string]*litestream.DB, len(dm.databases))
+	for clientID, db := range dm.databases {
+		databases[clientID] = db
+	}
+	dm.mutex.RUnlock()
+	
+	dm.closingMutex.Lock()
+	for clientID := range databases {
+		dm.closing[clientID] = true
+	}
+	dm.closingMutex.Unlock()
+	
+	// Sync final antes de fechar, para não interromper um upload no meio
+	if dm.config.ShutdownSyncTimeout > 0 && len(databases) > 0 {
+		dm.drain(databases)
+	}
+	
+	// Iteração otimizada usando clientID como chave
+	for clientID, db := range databases {
+		db.SoftClose()
+		dm.closingMutex.Lock()
+		delete(dm.closing, clientID)
+		dm.closingMutex.Unlock()
+		log.Printf("❌ Stopped replication: %s", clientID)
+	}
+	
+	log.Printf("📁 Database manager stopped")
+}
+
+// stopWithTimeout executa Stop, mas desiste depois de timeout (-shutdown-timeout)
+// registrando os clientes que não fecharam: um sync ou close preso na rede não
+// deve obrigar o systemd/Kubernetes a matar o processo com SIGKILL
+func (dm *DatabaseManager) stopWithTimeout(timeout time.Duration) {
+	if timeout <= 0 {
+		dm.Stop()
+		return
+	}
+
+	done := make(chan struct{})
+	go func() {
+		dm.Stop()
+		close(done)
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-done:
+		return
+	case <-timer.C:
+	}
+
+	dm.closingMutex.Lock()
+	clientIDs := make([]string, 0, len(dm.closing))
+	for clientID := range dm.closing {
+		clientIDs = append(clientIDs, clientID)
+	}
+	dm.closingMutex.Unlock()
+	sort.Strings(clientIDs)
+
+	log.Printf("⏱️  Shutdown timed out after %s, exiting with %d clients not closed: %s", timeout, len(clientIDs), strings.Join(clientIDs, ", "))
+}
+
+// drain executa o sync final dos bancos em paralelo, limitado por
+// -shutdown-sync-timeout (chamar sem o lock: os syncs acessam o S3)
+func (dm *DatabaseManager) drain(databases map[string]*litestream.DB) {
+	// dm.ctx já foi cancelado em Stop: o sync final tem o próprio limite
+	ctx, cancel := context.WithTimeout(context.Background(), dm.config.ShutdownSyncTimeout)
+	defer cancel()
+
+	var (
+		wg                       sync.WaitGroup
+		mu                       sync.Mutex
+		synced, timedOut, failed int
+	)
+	for clientID, lsdb := range databases {
+		wg.Add(1)
+		go func(clientID string, lsdb *litestream.DB) {
+			defer wg.Done()
+
+			err := dm.finalSync(ctx, clientID, lsdb)
+
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case err == nil:
+				synced++
+			case ctx.Err() != nil:
+				timedOut++
+				log.Printf("⏱️  Final sync timed out: %s", clientID)
+			default:
+				failed++
+				log.Printf("⚠️  Final sync failed for client %s: %v", clientID, err)
+			}
+		}(clientID, lsdb)
+	}
+	wg.Wait()
+
+	log.Printf("🏁 Final sync: %d synced, %d timed out, %d failed", synced, timedOut, failed)
+}
+
+// addWatchDir adiciona diretório para monitoramento
+func (dm *DatabaseManager) addWatchDir(dir string) error {
+	// Verificar se o diretório existe
+	info, err := os.Stat(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("directory does not exist: %s (please create it first)", dir)
+		}
+		return fmt.Errorf("failed to access directory %s: %w", dir, err)
+	}
+	
+	// Verificar se é realmente um diretório
+	if !info.IsDir() {
+		return fmt.Errorf("path is not a directory: %s", dir)
+	}
+	
+	// Verificar se temos permissão de escrita (para criar arquivos de teste).
+	// Nome único por chamada; eventos gerados por ele são ignorados (isIgnoredFile).
+	testFile, err := os.CreateTemp(dir, accessTestPrefix+"-*")
+	if err != nil {
+		return fmt.Errorf("directory is not writable: %s (error: %v)", dir, err)
+	}
+	defer os.Remove(testFile.Name()) // Limpar arquivo de teste
+	testFile.Close()
+	
+	// Arquivo de nome fixo deixado por versões anteriores interrompidas
+	os.Remove(filepath.Join(dir, accessTestPrefix))
+	
+	if dm.config.Recursive {
+		return dm.addWatchTree(dir)
+	}
+	return dm.watchPath(dir)
+}
+
+// addWatchTree registra o diretório e todos os seus subdiretórios no watcher.
+// WalkDir não segue symlinks, então links circulares não geram loops.
+func (dm *DatabaseManager) addWatchTree(root string) error {
+	if err := dm.watchPath(root); err != nil {
+		return err
+	}
+
+	return filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			log.Printf("⚠️  Failed to access %s: %v", path, err)
+			return nil
+		}
+		if path == root || !d.IsDir() {
+			return nil
+		}
+
+		// Diretórios internos do Litestream geram muitos eventos e nunca contêm clientes
+		if isLitestreamMetaDir(d.Name()) {
+			return filepath.SkipDir
+		}
+
+		if err := dm.watchPath(path); err != nil {
+			log.Printf("⚠️  Failed to watch subdirectory %s: %v", path, err)
+		}
+		return nil
+	})
+}
+
+// watchPath adiciona um único diretório ao watcher e registra no índice
+func (dm *DatabaseManager) watchPath(dir string) error {
+	if err := dm.watcher.Add(dir); err != nil {
+		return err
+	}
+
+	dm.mutex.Lock()
+	dm.watchedDirs[dir] = struct{}{}
+	dm.mutex.Unlock()
+	return nil
+}
+
+// unwatchTree remove do watcher o diretório e todos os subdiretórios abaixo dele
+func (dm *DatabaseManager) unwatchTree(root string) {
+	prefix := root + string(filepath.Separator)
+
+	dm.mutex.Lock()
+	var dirs []string
+	for dir := range dm.watchedDirs {
+		if dir == root || strings.HasPrefix(dir, prefix) {
+			dirs = append(dirs, dir)
+			delete(dm.watchedDirs, dir)
+		}
+	}
+	dm.mutex.Unlock()
+
+	for _, dir := range dirs {
+		// O kernel pode já ter descartado o watch de um diretório removido
+		dm.watcher.Remove(dir)
+	}
+
+	if len(dirs) > 0 {
+		log.Printf("👋 Stopped watching %d directories under %s", len(dirs), root)
+	}
+}
+
+// isWatchedDir verifica se o caminho é um diretório registrado no watcher
+func (dm *DatabaseManager) isWatchedDir(path string) bool {
+	dm.mutex.RLock()
+	defer dm.mutex.RUnlock()
+	_, exists := dm.watchedDirs[path]
+	return exists
+}
+
+// isLitestreamMetaDir verifica se o nome é um diretório interno do Litestream (.{name}-litestream)
+func isLitestreamMetaDir(name string) bool {
+	return strings.HasPrefix(name, ".") && strings.HasSuffix(name, litestream.MetaDirSuffix)
+}
+
+// watchFiles monitora mudanças nos arquivos
+// Eventos Write do mesmo arquivo dentro de -event-coalesce-window são agrupados
+// e tratados uma única vez ao fim da janela. Os demais eventos são tratados na
+// hora, depois do Write pendente do mesmo arquivo, para preservar a ordem.
+func (dm *DatabaseManager) watchFiles() {
+	window := dm.config.EventCoalesceWindow
+	writes := make(map[string]time.Time) // arquivo -> primeiro Write ainda não tratado
+
+	var flush <-chan time.Time
+	if window > 0 {
+		ticker := time.NewTicker(window / 2)
+		defer ticker.Stop()
+		flush = ticker.C
+	}
+
+	emitWrite := func(name string) {
+		delete(writes, name)
+		dm.handleFileEvent(fsnotify.Event{Name: name, Op: fsnotify.Write})
+	}
+
+	for {
+		select {
+		case <-dm.ctx.Done():
+			return
+		case now := <-flush:
+			for name, first := range writes {
+				if now.Sub(first) >= window {
+					emitWrite(name)
+				}
+			}
+		case event, ok := <-dm.watcher.Events:
+			if !ok {
+				return
+			}
+			if window <= 0 {
+				dm.handleFileEvent(event)
+				continue
+			}
+			if event.Op == fsnotify.Write {
+				if _, exists := writes[event.Name]; !exists {
+					writes[event.Name] = time.Now()
+				}
+				continue
+			}
+			if _, exists := writes[event.Name]; exists {
+				emitWrite(event.Name)
+			}
+			dm.handleFileEvent(event)
+		case err, ok := <-dm.watcher.Errors:
+			if !ok {
+				return
+			}
+			// Overflow da fila do inotify: eventos (inclusive Create) foram
+			// descartados, então só um rescan completo encontra os bancos novos
+			if errors.Is(err, fsnotify.ErrEventOverflow) {
+				watcherOverflowsTotal.Inc()
+				logEvent(MsgWatcherOverflow)
+				dm.requestRescan()
+				continue
+			}
+			log.Printf("⚠️  File watcher error: %v", err)
+		}
+	}
+}
+
+// handleFileEvent processa eventos de arquivo
+func (dm *DatabaseManager) handleFileEvent(event fsnotify.Event) {
+	if dm.config.Recursive && dm.handleDirEvent(event) {
+		return
+	}
+
+	if strings.HasSuffix(event.Name, metadataSuffix) {
+		dm.reloadMetadata(event.Name)
+		return
+	}
+
+	if !dm.isDatabaseFile(event.Name) {
+		return
+	}
+
+	// O restore in-place remove e registra o cliente por conta própria
+	if dm.isRestoring(event.Name) {
+		return
+	}
+
+	switch {
+	case event.Op&fsnotify.Create == fsnotify.Create:
+		if dm.extractClientID(event.Name) == "" {
+			return
+		}
+		if dm.isReleased(event.Name) {
+			debugf("Skipping %s: client was removed via the API", event.Name)
+			return
+		}
+		log.Printf("📁 Database created: %s", event.Name)
+		if dm.config.RegisterDebounce > 0 {
+			dm.scheduleRegistration(event.Name)
+		} else if err := dm.registerDatabase(event.Name); err != nil {
+			dm.queueRetry(event.Name, err)
+		}
+	case event.Op&fsnotify.Remove == fsnotify.Remove:
+		if dm.cancelRegistration(event.Name) {
+			log.Printf("⏹️  Pending registration cancelled: %s", event.Name)
+		}
+		if dm.isDatabaseFile(event.Name) {
+			log.Printf("🗑️  Database removed: %s", event.Name) 
+			dm.forgetReleased(event.Name)
+			dm.unregisterDatabase(event.Name)
+		}
+	case event.Op&fsnotify.Rename == fsnotify.Rename:
+		// O caminho antigo deixou de existir. O destino chega como Create quando
+		// está em um diretório monitorado; senão, a varredura do diretório (feita
+		// pelo rescanLoop, fora do goroutine do watcher) o encontra.
+		dm.cancelRegistration(event.Name)
+		log.Printf("🔀 Database renamed or moved: %s", event.Name)
+		dm.forgetReleased(event.Name)
+		dm.unregisterDatabase(event.Name)
+		dm.requestDirScan(filepath.Dir(event.Name))
+	case event.Op&fsnotify.Write == fsnotify.Write:
+		// Arquivo modificado - já está sendo replicado.
+		// Se o registro ainda está aguardando, reinicia o período de silêncio.
+		if dm.hasPendingRegistration(event.Name) {
+			dm.scheduleRegistration(event.Name)
+		}
+	}
+}
+
+// displayName nome de exibição; vazio sem arquivo de metadados
+func (m *ClientMetadata) displayName() string {
+	if m == nil {
+		return ""
+	}
+	return m.DisplayName
+}
+
+// tags tags de exibição; vazio (não nulo) sem arquivo de metadados
+func (m *ClientMetadata) tags() map[string]string {
+	if m == nil || m.Tags == nil {
+		return map[string]string{}
+	}
+	return m.Tags
+}
+
+// metadataSuffix sufixo do arquivo de metadados ao lado do banco ({clientID}.meta.json)
+const metadataSuffix = ".meta.json"
+
+// metadataPath caminho do arquivo de metadados de um banco
+func metadataPath(dbPath, clientID string) string {
+	return filepath.Join(filepath.Dir(dbPath), clientID+metadataSuffix)
+}
+
+// readClientMetadata lê o arquivo de metadados; retorna nil sem erro se ele não existe
+func readClientMetadata(path string) (*ClientMetadata, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var meta ClientMetadata
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", filepath.Base(path), err)
+	}
+	return &meta, nil
+}
+
+// reloadMetadata aplica um arquivo de metadados criado, alterado ou removido
+// aos clientes do mesmo diretório. Um arquivo inválido (ex: gravação ainda
+// em andamento) mantém os metadados anteriores.
+func (dm *DatabaseManager) reloadMetadata(path string) {
+	meta, err := readClientMetadata(path)
+	if err != nil {
+		log.Printf("⚠️  Keeping previous metadata: %v", err)
+		return
+	}
+
+	dm.mutex.Lock()
+	defer dm.mutex.Unlock()
+
+	for clientID, config := range dm.clients {
+		if metadataPath(config.DatabasePath, dm.config.IDStrategy.Extract(config.DatabasePath)) != path {
+			continue
+		}
+		config.Metadata = meta
+		debugf("Metadata reloaded for client %s", clientID)
+	}
+}
+
+// scheduleRegistration agenda o registro do banco após o período de debounce.
+// Cada nova chamada para o mesmo arquivo reinicia a contagem.
+func (dm *DatabaseManager) scheduleRegistration(dbPath string) {
+	dm.pendingMutex.Lock()
+	defer dm.pendingMutex.Unlock()
+
+	if existing, exists := dm.pending[dbPath]; exists {
+		existing.Stop()
+	}
+
+	var timer *time.Timer
+	timer = time.AfterFunc(dm.config.RegisterDebounce, func() {
+		dm.pendingMutex.Lock()
+		if dm.pending[dbPath] != timer {
+			// Substituído por um agendamento mais recente ou cancelado
+			dm.pendingMutex.Unlock()
+			return
+		}
+		delete(dm.pending, dbPath)
+		dm.pendingMutex.Unlock()
+
+		if dm.ctx.Err() != nil {
+			return
+		}
+		if _, err := os.Stat(dbPath); err != nil {
+			return
+		}
+		if dm.isPathRegistered(dbPath) {
+			return // já registrado pelo rescan de um rename
+		}
+		if err := dm.registerDatabase(dbPath); err != nil && !dm.queueRetry(dbPath, err) {
+			log.Printf("⚠️  Failed to register database %s: %v", dbPath, err)
+		}
+	})
+	dm.pending[dbPath] = timer
+}
+
+// cancelRegistration cancela um registro agendado; retorna true se havia um
+func (dm *DatabaseManager) cancelRegistration(dbPath string) bool {
+	dm.pendingMutex.Lock()
+	defer dm.pendingMutex.Unlock()
+
+	timer, exists := dm.pending[dbPath]
+	if !exists {
+		return false
+	}
+	timer.Stop()
+	delete(dm.pending, dbPath)
+	return true
+}
+
+// hasPendingRegistration verifica se há registro agendado para o arquivo
+func (dm *DatabaseManager) hasPendingRegistration(dbPath string) bool {
+	dm.pendingMutex.Lock()
+	defer dm.pendingMutex.Unlock()
+	_, exists := dm.pending[dbPath]
+	return exists
+}
+
+// handleDirEvent trata criação/remoção de subdiretórios no modo recursivo.
+// Retorna true se o evento era de um diretório e já foi processado.
+func (dm *DatabaseManager) handleDirEvent(event fsnotify.Event) bool {
+	switch {
+	case event.Op&fsnotify.Create == fsnotify.Create:
+		info, err := os.Lstat(event.Name)
+		if err != nil || !info.IsDir() {
+			return false
+		}
+		if isLitestreamMetaDir(info.Name()) {
+			return true
+		}
+
+		if err := dm.addWatchTree(event.Name); err != nil {
+			log.Printf("⚠️  Failed to watch new directory %s: %v", event.Name, err)
+			return true
+		}
+		log.Printf("👀 Watching new directory: %s", event.Name)
+
+		// Arquivos podem ter sido criados antes do watch ser registrado; a
+		// varredura fica com o rescanLoop para não travar o goroutine do watcher
+		dm.requestDirScan(event.Name)
+		return true
+
+	case event.Op&(fsnotify.Remove|fsnotify.Rename) != 0:
+		if !dm.isWatchedDir(event.Name) {
+			return false
+		}
+		dm.unwatchTree(event.Name)
+		return true
+	}
+
+	return false
+}
+
+// scanDirectory registra os bancos encontrados em um diretório (recursivamente).
+// Os registros rodam em paralelo, limitados por -scan-concurrency.
+// WalkDir usa o tipo das entradas do diretório sem um stat por arquivo, o que
+// importa em diretórios com dezenas de milhares de bancos.
+func (dm *DatabaseManager) scanDirectory(dir string) {
+	var paths []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == dir {
+				return err
+			}
+			log.Printf("⚠️  Failed to access %s: %v", path, err)
+			return nil
+		}
+
+		// Diretórios internos do Litestream (WAL e snapshots) nunca contêm clientes
+		if d.IsDir() {
+			if path != dir && isLitestreamMetaDir(d.Name()) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if dm.isDatabaseFile(path) {
+			clientID := dm.extractClientID(path)
+			if clientID != "" && !dm.isClientRegistered(clientID) && !dm.isTracked(path) {
+				paths = append(paths, path)
+			}
+		}
+		return nil
+	})
+	
+	if err != nil {
+		log.Printf("⚠️  Failed to scan directory %s: %v", dir, err)
+	}
+	if len(paths) == 0 {
+		return
+	}
+
+	workers := dm.config.ScanConcurrency
+	if workers < 1 {
+		workers = 1
+	}
+
+	var (
+		wg         sync.WaitGroup
+		mu         sync.Mutex
+		registered int
+		failed     int
+	)
+	jobs := make(chan string)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for path := range jobs {
+				err := dm.registerDatabase(path)
+				if err != nil && !dm.queueRetry(path, err) {
+					log.Printf("⚠️  Failed to register existing database %s: %v", path, err)
+				}
+				mu.Lock()
+				if err != nil {
+					failed++
+				} else {
+					registered++
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, path := range paths {
+		jobs <- path
+	}
+	close(jobs)
+	wg.Wait()
+
+	log.Printf("🔎 Scanned %s: %d registered, %d failed", dir, registered, failed)
+}
+
+// CompressLZ4 única compressão do Litestream v0.3.8, sempre aplicada a
+// snapshots e segmentos de WAL
+const CompressLZ4 = "lz4"
+
+// checkCompression recusa -compress que o Litestream não consegue aplicar, em
+// vez de ignorá-lo: o replica não expõe algoritmo nem nível de compressão
+func checkCompression(compress string) error {
+	if strings.ToLower(compress) == CompressLZ4 {
+		return nil
+	}
+	return fmt.Errorf("invalid -compress %q: the bundled Litestream (v0.3.8) always compresses snapshots and WAL segments with lz4 and has no option to change the algorithm or level", compress)
+}
+
+// checkS3Tags recusa -s3-tags em vez de ignorá-lo: o ReplicaClient S3 do
+// Litestream v0.3.8 faz os uploads sem tags nem metadados configuráveis, então
+// nem as tags informadas nem a tag automática clientId chegariam aos objetos
+func checkS3Tags(list string) error {
+	if strings.TrimSpace(list) == "" {
+		return nil
+	}
+	for _, pair := range strings.Split(list, ",") {
+		if i := strings.Index(pair, "="); i < 0 || strings.TrimSpace(pair[:i]) == "" {
+			return fmt.Errorf("invalid -s3-tags %q: expected key=value pairs separated by commas", list)
+		}
+	}
+	return fmt.Errorf("invalid -s3-tags %q: the bundled Litestream (v0.3.8) uploads objects without tags or metadata; use a bucket lifecycle rule on the replica prefix (e.g. databases/) or tag objects outside the manager", list)
+}
+
+// checkS3ObjectSecurity valida -s3-sse, -s3-sse-kms-key-id e -s3-acl e os recusa:
+// o ReplicaClient S3 do Litestream v0.3.8 envia os objetos sem cabeçalhos de
+// criptografia nem ACL, e ignorá-los deixaria os backups fora da política exigida
+func checkS3ObjectSecurity(sse, kmsKeyID, acl string) error {
+	switch sse {
+	case "", "AES256":
+		if kmsKeyID != "" {
+			return fmt.Errorf("invalid -s3-sse-kms-key-id: requires -s3-sse aws:kms")
+		}
+	case "aws:kms":
+		if kmsKeyID == "" {
+			return fmt.Errorf("invalid -s3-sse aws:kms: -s3-sse-kms-key-id is required")
+		}
+	default:
+		return fmt.Errorf("invalid -s3-sse %q: must be AES256 or aws:kms", sse)
+	}
+
+	if sse == "" && acl == "" {
+		return nil
+	}
+	return fmt.Errorf("-s3-sse and -s3-acl are not supported: the bundled Litestream (v0.3.8) uploads objects without encryption or ACL headers; enforce them on the bucket instead (default encryption with the KMS key and Object Ownership \"bucket owner enforced\")")
+}
+
+// checkMetaDir recusa -meta-dir em vez de ignorá-lo: no Litestream v0.3.8 o
+// diretório interno é sempre .{nome}-litestream ao lado do banco (DB.MetaPath
+// é derivado do caminho do arquivo e não pode ser configurado)
+func checkMetaDir(dir string) error {
+	if dir == "" {
+		return nil
+	}
+	return fmt.Errorf("invalid -meta-dir %q: the bundled Litestream (v0.3.8) always keeps its metadata in .{name}-litestream next to the database and has no option to move it; mount the watch dir on a volume with room for it instead", dir)
+}
+
+// DefaultDBExtensions extensões reconhecidas como banco quando -db-extensions não é informado
+const DefaultDBExtensions = ".db,.sqlite,.sqlite3"
+
+// parseDBExtensions normaliza a lista de -db-extensions (minúsculas, com ponto)
+func parseDBExtensions(list string) (map[string]bool, error) {
+	extensions := make(map[string]bool)
+	for _, ext := range strings.Split(list, ",") {
+		ext = strings.ToLower(strings.TrimSpace(ext))
+		if ext == "" {
+			continue
+		}
+		if !strings.HasPrefix(ext, ".") {
+			ext = "." + ext
+		}
+		extensions[ext] = true
+	}
+	if len(extensions) == 0 {
+		return nil, fmt.Errorf("invalid -db-extensions %q: at least one extension is required", list)
+	}
+	return extensions, nil
+}
+
+// parseWatchGlobs valida os padrões de -watch-glob (separados por vírgula)
+func parseWatchGlobs(list string) ([]string, error) {
+	var globs []string
+	for _, glob := range strings.Split(list, ",") {
+		if glob = strings.TrimSpace(glob); glob == "" {
+			continue
+		}
+		if _, err := filepath.Match(glob, ""); err != nil {
+			return nil, fmt.Errorf("invalid -watch-glob %q: %w", glob, err)
+		}
+		globs = append(globs, glob)
+	}
+	return globs, nil
+}
+
+// matchesWatchGlob verifica o nome do arquivo contra -watch-glob (sem globs, tudo passa)
+func (dm *DatabaseManager) matchesWatchGlob(filename string) bool {
+	if len(dm.config.WatchGlobs) == 0 {
+		return true
+	}
+	base := filepath.Base(filename)
+	for _, glob := range dm.config.WatchGlobs {
+		if ok, _ := filepath.Match(glob, base); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// accessTestPrefix prefixo do arquivo de teste de escrita criado em addWatchDir
+const accessTestPrefix = ".litestream-access-test"
+
+// restoreTempSuffix arquivo temporário dos restores feitos ao lado do banco
+// (-restore-on-create e restore in-place); o Litestream ainda grava em
+// {arquivo}.tmp antes de renomeá-lo
+const restoreTempSuffix = ".restoring"
+
+// sqliteSidecarSuffixes arquivos auxiliares do SQLite ao lado do banco
+var sqliteSidecarSuffixes = []string{"-wal", "-shm", "-journal"}
+
+// isIgnoredFile arquivos que nunca são bancos de clientes: conteúdo do diretório
+// interno do Litestream, sidecars do SQLite, o arquivo de teste de escrita e os
+// temporários de restore. O scan e o watcher usam a mesma regra.
+func isIgnoredFile(filename string) bool {
+	for _, part := range strings.Split(filepath.ToSlash(filepath.Dir(filename)), "/") {
+		if isLitestreamMetaDir(part) {
+			return true
+		}
+	}
+
+	base := filepath.Base(filename)
+	if strings.HasPrefix(base, accessTestPrefix) {
+		return true
+	}
+	if strings.HasSuffix(base, restoreTempSuffix) || strings.HasSuffix(base, restoreTempSuffix+".tmp") {
+		return true
+	}
+	for _, suffix := range sqliteSidecarSuffixes {
+		if strings.HasSuffix(strings.ToLower(base), suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// isDatabaseFile verifica se é arquivo de banco
+func (dm *DatabaseManager) isDatabaseFile(filename string) bool {
+	if isIgnoredFile(filename) || !dm.matchesWatchGlob(filename) {
+		return false
+	}
+	ext := strings.ToLower(filepath.Ext(filename))
+	if ext == "" {
+		// Sem extensão apenas com -db-no-extension, e nunca diretórios
+		if !dm.config.DBNoExtension {
+			return false
+		}
+		info, err := os.Stat(filename)
+		return err != nil || !info.IsDir()
+	}
+	return dm.config.DBExtensions[ext]
+}
+
+// extractClientID extrai o clientID conforme -id-strategy.
+// Nomes ignorados são registrados em nível debug.
+func (dm *DatabaseManager) extractClientID(dbPath string) string {
+	clientID := dm.config.IDStrategy.Extract(dbPath)
+	if clientID == "" {
+		debugf("Skipping %s: filename does not match id strategy %s", dbPath, dm.config.IDStrategy)
+	}
+	return clientID
+}
+
+// isClientRegistered verifica se cliente já está registrado
+func (dm *DatabaseManager) isClientRegistered(clientID string) bool {
+	dm.mutex.RLock()
+	defer dm.mutex.RUnlock()
+	_, exists := dm.databases[clientID]
+	return exists
+}
+
+// isTracked verifica se o arquivo já está registrado ou aguardando registro
+// (debounce, nova tentativa ou falha definitiva), para a varredura não repeti-lo
+func (dm *DatabaseManager) isTracked(dbPath string) bool {
+	if dm.isPathRegistered(dbPath) || dm.hasPendingRegistration(dbPath) {
+		return true
+	}
+
+	dm.mutex.RLock()
+	defer dm.mutex.RUnlock()
+	_, retrying := dm.retries[dbPath]
+	_, failed := dm.failed[dbPath]
+	_, released := dm.released[dbPath]
+	return retrying || failed || released || dm.restoring[dbPath]
+}
+
+// isReleased verifica se o arquivo pertence a um cliente removido pela API
+func (dm *DatabaseManager) isReleased(dbPath string) bool {
+	dm.mutex.RLock()
+	defer dm.mutex.RUnlock()
+	_, released := dm.released[dbPath]
+	return released
+}
+
+// forgetReleased volta a aceitar o caminho: o arquivo do cliente removido sumiu
+// e um novo banco nesse lugar é outro cliente
+func (dm *DatabaseManager) forgetReleased(dbPath string) {
+	dm.mutex.Lock()
+	defer dm.mutex.Unlock()
+	delete(dm.released, dbPath)
+}
+
+// isRestoring verifica se o arquivo está sendo trocado por um restore in-place
+func (dm *DatabaseManager) isRestoring(dbPath string) bool {
+	dm.mutex.RLock()
+	defer dm.mutex.RUnlock()
+	return dm.restoring[dbPath]
+}
+
+// isPathRegistered verifica se o arquivo já está mapeado para um cliente
+func (dm *DatabaseManager) isPathRegistered(dbPath string) bool {
+	dm.mutex.RLock()
+	defer dm.mutex.RUnlock()
+	_, exists := dm.pathIndex[dbPath]
+	return exists
+}
+
+// registerDatabase registra novo cliente (1:1 otimizado)
+func (dm *DatabaseManager) registerDatabase(dbPath string) error {
+	started := time.Now()
+
+	// Extrai clientID do filename (-id-strategy)
+	clientID := dm.extractClientID(dbPath)
+	if clientID == "" {
+		return fmt.Errorf("filename does not match id strategy %s: %s", dm.config.IDStrategy, filepath.Base(dbPath))
+	}
+
+	// Arquivo com nome de banco mas conteúdo de outro tipo (ou corrompido)
+	if err := checkSQLiteHeader(dbPath); errors.Is(err, errNotSQLite) {
+		dm.recordProblem(clientID, dbPath, ProblemNotSQLite, err)
+		return err
+	} else if err != nil {
+		return &retriableError{err}
+	}
+
+	// Arquivo grande demais para ser um banco de cliente (-max-db-size)
+	if err := dm.checkDBSize(clientID, dbPath); err != nil {
+		return err
+	}
+
+	// Reserva o clientID; o lock não é mantido durante lsdb.Open(),
+	// que pode restaurar do S3 e levar segundos
+	baseID := clientID
+	var suffix, collidesWith string
+	if err := dm.reserveClient(clientID, dbPath); err != nil {
+		var collision *idCollisionError
+		if !errors.As(err, &collision) {
+			return err
+		}
+		if dm.config.OnIDCollision != IDCollisionSuffix {
+			dm.recordCollision(collision)
+			return err
+		}
+
+		// -on-id-collision=suffix: usa o nome do diretório pai para diferenciar
+		suffix = "-" + filepath.Base(filepath.Dir(dbPath))
+		collidesWith = collision.ExistingPath
+		clientID = baseID + suffix
+		log.Printf("⚠️  Client ID collision: %s is also used by %s, registering as %s", baseID, collidesWith, clientID)
+		if err := dm.reserveClient(clientID, dbPath); err != nil {
+			return err
+		}
+	}
+	defer func() {
+		dm.mutex.Lock()
+		delete(dm.opening, clientID)
+		dm.mutex.Unlock()
+	}()
+	
+	// Path do replica a partir de -s3-path-template
+	s3Path, err := dm.replicaPath(baseID)
+	if err != nil {
+		registrationFailuresTotal.Inc()
+		return fmt.Errorf("failed to render replica path for client %s: %w", clientID, err)
+	}
+	s3Path += suffix
+	
+	// Cria configuração otimizada
+	config := &ClientConfig{
+		ClientID:     clientID,
+		DatabasePath: dbPath,
+		S3Path:       s3Path,
+		CreatedAt:    time.Now(),
+		CollidesWith: collidesWith,
+	}
+
+	// Nome e tags opcionais; um arquivo inválido não impede a replicação
+	metaPath := metadataPath(dbPath, baseID)
+	if meta, err := readClientMetadata(metaPath); err != nil {
+		log.Printf("⚠️  Ignoring metadata for client %s: %v", clientID, err)
+	} else {
+		config.Metadata = meta
+	}
+
+	// -dry-run: registra apenas a configuração, sem replicas nem lsdb.Open()
+	if dm.config.DryRun {
+		dm.mutex.Lock()
+		defer dm.mutex.Unlock()
+
+		dm.clients[clientID] = config
+		dm.pathIndex[dbPath] = clientID
+		delete(dm.retries, dbPath)
+		delete(dm.failed, dbPath)
+
+		for _, bucket := range dm.bucketsFor(dbPath) {
+			log.Printf("🧪 [dry-run] Would replicate client %s: %s -> %s/", clientID, dbPath, dm.config.replicaURL(bucket, s3Path))
+		}
+		return nil
+	}
+
+	// Cria e inicializa a instância Litestream
+	lsdb, err := dm.openDatabase(baseID, dbPath, s3Path)
+	config.RegistrationDuration = time.Since(started)
+	registrationDurationSeconds.Observe(config.RegistrationDuration.Seconds())
+	if err != nil {
+		registrationFailuresTotal.Inc()
+		dm.recordProblem(clientID, dbPath, ProblemOpenError, err)
+		return &retriableError{fmt.Errorf("open error: %w", err)}
+	}
+
+	dm.mutex.Lock()
+	defer dm.mutex.Unlock()
+
+	// Confere novamente: outro caminho pode ter registrado o cliente durante o Open
+	if _, exists := dm.databases[clientID]; exists {
+		go lsdb.Close()
+		return fmt.Errorf("client already registered: %s", clientID)
+	}
+
+	// Registra usando clientID como chave primária
+	delete(dm.problems, dbPath)
+	dm.databases[clientID] = lsdb
+	dm.clients[clientID] = config
+	dm.pathIndex[dbPath] = clientID
+	delete(dm.retries, dbPath)
+	delete(dm.failed, dbPath)
+	registrationsTotal.Inc()
+
+	for _, bucket := range dm.bucketsFor(dbPath) {
+		logEvent(MsgClientRegistered, 
+			clientID, dm.config.replicaURL(bucket, s3Path))
+	}
+	debugf("Client %s registered in %s", clientID, config.RegistrationDuration.Round(time.Millisecond))
+	dm.audit("registered", clientID, dbPath, s3Path)
+
+	return nil
+}
+
+// AuditEvent linha do -audit-log (JSON Lines)
+type AuditEvent struct {
+	Event     string    `json:"event"` // registered, unregistered, paused, resumed ou restore
+	ClientID  string    `json:"clientId"`
+	Path      string    `json:"path"` // banco do cliente ou arquivo gerado pelo restore
+	S3Path    string    `json:"s3Path"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// auditLog arquivo append-only com os eventos de ciclo de vida dos clientes
+type auditLog struct {
+	mutex sync.Mutex
+	file  *os.File
+}
+
+// openAuditLog abre (ou cria) o arquivo mantendo os eventos anteriores
+func openAuditLog(path string) (*auditLog, error) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log: %w", err)
+	}
+	return &auditLog{file: file}, nil
+}
+
+// Record grava o evento e força a escrita em disco; sem -audit-log não faz nada
+func (a *auditLog) Record(event AuditEvent) {
+	if a == nil {
+		return
+	}
+
+	line, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("⚠️  Failed to encode audit event: %v", err)
+		return
+	}
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if _, err := a.file.Write(append(line, '\n')); err != nil {
+		log.Printf("⚠️  Failed to write audit log: %v", err)
+		return
+	}
+	if err := a.file.Sync(); err != nil {
+		log.Printf("⚠️  Failed to sync audit log: %v", err)
+	}
+}
+
+// Close fecha o arquivo
+func (a *auditLog) Close() error {
+	if a == nil {
+		return nil
+	}
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	return a.file.Close()
+}
+
+// audit registra um evento do cliente no -audit-log e o transmite em /api/events
+func (dm *DatabaseManager) audit(event, clientID, path, s3Path string) {
+	now := time.Now().UTC()
+	dm.config.Audit.Record(AuditEvent{
+		Event:     event,
+		ClientID:  clientID,
+		Path:      path,
+		S3Path:    s3Path,
+		Timestamp: now,
+	})
+	dm.events.Publish(ClientEvent{Event: event, ClientID: clientID, Path: path, Timestamp: now})
+}
+
+// ClientEvent evento transmitido em /api/events
+type ClientEvent struct {
+	Event     string    `json:"event"` // eventos do audit log, synced ou failed
+	ClientID  string    `json:"clientId"`
+	Path      string    `json:"path,omitempty"`
+	Error     string    `json:"error,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// eventBufferSize eventos guardados por assinante antes de começar a descartar
+const eventBufferSize = 64
+
+// eventBus distribui os eventos dos clientes para os assinantes de /api/events.
+// Publish nunca bloqueia: um assinante lento perde eventos em vez de travar o manager.
+type eventBus struct {
+	mutex       sync.Mutex
+	subscribers map[chan ClientEvent]bool // canal -> descartando eventos (já avisado no log)
+}
+
+func newEventBus() *eventBus {
+	return &eventBus{subscribers: make(map[chan ClientEvent]bool)}
+}
+
+// Subscribe cria um canal que recebe os próximos eventos
+func (b *eventBus) Subscribe() chan ClientEvent {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	ch := make(chan ClientEvent, eventBufferSize)
+	b.subscribers[ch] = false
+	return ch
+}
+
+// Unsubscribe remove e fecha o canal do assinante
+func (b *eventBus) Unsubscribe(ch chan ClientEvent) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	delete(b.subscribers, ch)
+	close(ch)
+}
+
+// Publish entrega o evento a todos os assinantes com espaço no buffer
+func (b *eventBus) Publish(event ClientEvent) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	for ch, dropping := range b.subscribers {
+		select {
+		case ch <- event:
+			b.subscribers[ch] = false
+		default:
+			if !dropping {
+				log.Printf("⚠️  Slow /api/events consumer: dropping events until it catches up")
+				b.subscribers[ch] = true
+			}
+		}
+	}
+}
+
+// Notificações de falha de replicação (-webhook-url)
+const (
+	webhookFailureThreshold = 3                // verificações seguidas com erro antes de notificar
+	webhookCooldown         = 10 * time.Minute // intervalo mínimo entre notificações de falha do mesmo cliente
+	webhookTimeout          = 10 * time.Second
+)
+
+// WebhookEvent payload enviado para -webhook-url
+type WebhookEvent struct {
+	Event     string    `json:"event"` // replication_failed, replication_recovered ou first_replicated
+	ClientID  string    `json:"clientId"`
+	Error     string    `json:"error,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// syncFailureState falhas consecutivas de sync de um cliente
+type syncFailureState struct {
+	failures   int
+	lastError  string
+	degraded   bool      // falhas seguidas atingiram webhookFailureThreshold
+	nextCheck  time.Time // backoff: antes disso o sync não é forçado de novo
+	notified   bool      // replication_failed enviado e ainda não recuperado
+	notifiedAt time.Time // último replication_failed enviado
+}
+
+// maxHealthBackoff limite do intervalo entre syncs forçados de um cliente com falha
+const maxHealthBackoff = 10 * time.Minute
+
+// defaultHealthCheckInterval -webhook-check-interval quando há -webhook-url e o
+// intervalo não foi informado
+const defaultHealthCheckInterval = 30 * time.Second
+
+// healthCheckConcurrency clientes verificados ao mesmo tempo por monitorReplication
+const healthCheckConcurrency = 8
+
+// S3Health saúde da replicação de um cliente em /api/status
+type S3Health struct {
+	Status    string `json:"status"` // ok, failing (erros recentes) ou degraded (erros persistentes)
+	Failures  int    `json:"failures,omitempty"`
+	LastError string `json:"lastError,omitempty"`
+	NextCheck string `json:"nextCheck,omitempty"`
+}
+
+// s3Health estado de saúde do S3 do cliente (chamar com o lock)
+func (dm *DatabaseManager) s3Health(clientID string) S3Health {
+	state, exists := dm.syncFailures[clientID]
+	if !exists || state.failures == 0 {
+		return S3Health{Status: "ok"}
+	}
+	health := S3Health{Status: "failing", Failures: state.failures, LastError: state.lastError}
+	if state.degraded {
+		health.Status = "degraded"
+	}
+	if !state.nextCheck.IsZero() {
+		health.NextCheck = formatTimestamp(state.nextCheck)
+	}
+	return health
+}
+
+// monitorReplication sincroniza os replicas periodicamente para acompanhar a
+// saúde do S3 de cada cliente, com backoff exponencial para clientes com erro,
+// e notifica o webhook quando os erros persistem ou quando o cliente se recupera.
+// Até healthCheckConcurrency clientes são sincronizados ao mesmo tempo.
+func (dm *DatabaseManager) monitorReplication() {
+	ticker := time.NewTicker(dm.config.WebhookCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-dm.ctx.Done():
+			return
+		case now := <-ticker.C:
+			dm.mutex.RLock()
+			replicas := make(map[string][]*litestream.Replica, len(dm.databases))
+			for clientID, lsdb := range dm.databases {
+				if state, exists := dm.syncFailures[clientID]; exists && now.Before(state.nextCheck) {
+					continue // em backoff após falhas
+				}
+				replicas[clientID] = append([]*litestream.Replica(nil), lsdb.Replicas...)
+			}
+			dm.mutex.RUnlock()
+
+			var wg sync.WaitGroup
+			slots := make(chan struct{}, healthCheckConcurrency)
+			for clientID, list := range replicas {
+				wg.Add(1)
+				slots <- struct{}{}
+				go func(clientID string, list []*litestream.Replica) {
+					defer func() { <-slots; wg.Done() }()
+					dm.checkReplication(clientID, list)
+				}(clientID, list)
+			}
+			wg.Wait()
+		}
+	}
+}
+
+// checkReplication sincroniza os replicas de um cliente e registra o resultado
+func (dm *DatabaseManager) checkReplication(clientID string, replicas []*litestream.Replica) {
+	var syncErr error
+	start := time.Now()
+	for _, replica := range replicas {
+		ctx, cancel := context.WithTimeout(dm.ctx, dm.config.WebhookCheckInterval)
+		err := replica.Sync(ctx)
+		cancel()
+		if err != nil {
+			syncErr = fmt.Errorf("replica %s: %w", replica.Name(), err)
+			break
+		}
+	}
+	if syncErr == nil {
+		dm.observeSync(clientID, time.Since(start))
+	}
+	dm.recordSyncResult(clientID, syncErr)
+}
+
+// recordSyncResult atualiza o estado de falhas do cliente e envia as notificações
+func (dm *DatabaseManager) recordSyncResult(clientID string, syncErr error) {
+	dm.mutex.Lock()
+	state, exists := dm.syncFailures[clientID]
+	if !exists {
+		state = &syncFailureState{}
+		dm.syncFailures[clientID] = state
+	}
+
+	var event *WebhookEvent
+	now := time.Now()
+	if syncErr == nil {
+		dm.events.Publish(ClientEvent{Event: "synced", ClientID: clientID, Timestamp: now.UTC()})
+		if state.degraded {
+			logEvent(MsgS3Recovered, clientID, state.failures)
+		}
+		if state.notified {
+			event = &WebhookEvent{Event: "replication_recovered", ClientID: clientID, Timestamp: now}
+		}
+		delete(dm.syncFailures, clientID)
+	} else {
+		state.failures++
+		state.lastError = syncErr.Error()
+		dm.events.Publish(ClientEvent{Event: "failed", ClientID: clientID, Error: syncErr.Error(), Timestamp: now.UTC()})
+
+		// Backoff: o intervalo de verificação dobra a cada falha seguida
+		backoff := dm.config.WebhookCheckInterval << uint(state.failures-1)
+		if backoff <= 0 || backoff > maxHealthBackoff {
+			backoff = maxHealthBackoff
+		}
+		state.nextCheck = now.Add(backoff)
+
+		if state.failures >= webhookFailureThreshold && !state.degraded {
+			state.degraded = true
+			logEvent(MsgS3Degraded, clientID, state.failures, backoff, syncErr)
+		}
+		if state.degraded && !state.notified && now.Sub(state.notifiedAt) >= webhookCooldown {
+			state.notified = true
+			state.notifiedAt = now
+			event = &WebhookEvent{Event: "replication_failed", ClientID: clientID, Error: syncErr.Error(), Timestamp: now}
+		}
+	}
+	dm.mutex.Unlock()
+
+	if event != nil && dm.config.WebhookURL != "" {
+		go dm.sendWebhook(*event)
+	}
+}
+
+// sendWebhook envia o evento para -webhook-url
+func (dm *DatabaseManager) sendWebhook(event WebhookEvent) {
+	body, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("⚠️  Failed to encode webhook event: %v", err)
+		return
+	}
+
+	client := &http.Client{Timeout: webhookTimeout}
+	resp, err := client.Post(dm.config.WebhookURL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		log.Printf("⚠️  Failed to send webhook %s for client %s: %v", event.Event, event.ClientID, err)
+		return
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		log.Printf("⚠️  Webhook %s for client %s returned %s", event.Event, event.ClientID, resp.Status)
+		return
+	}
+	logEvent(MsgWebhookSent, event.Event, event.ClientID)
+}
+
+// openDatabase cria a instância Litestream com um replica S3 por bucket e a abre
+func (dm *DatabaseManager) openDatabase(clientID, dbPath, s3Path string) (*litestream.DB, error) {
+	// Banco bloqueado por outro processo: tenta de novo com backoff até -open-busy-timeout
+	deadline := time.Now().Add(dm.config.OpenBusyTimeout)
+	for delay := openBusyRetryDelay; ; delay *= 2 {
+		lsdb, err := dm.openDatabaseOnce(clientID, dbPath, s3Path)
+		if err == nil || !isBusyError(err) || time.Now().Add(delay).After(deadline) {
+			return lsdb, err
+		}
+		debugf("Database is locked, retrying in %s: %s", delay, dbPath)
+		select {
+		case <-dm.ctx.Done():
+			return nil, err
+		case <-time.After(delay):
+		}
+	}
+}
+
+// openBusyRetryDelay primeira espera entre tentativas de abrir um banco bloqueado (dobra a cada uma)
+const openBusyRetryDelay = 100 * time.Millisecond
+
+// isBusyError identifica SQLITE_BUSY/SQLITE_LOCKED; o Litestream repassa os erros
+// do SQLite como texto, então a comparação é pela mensagem
+func isBusyError(err error) bool {
+	msg := err.Error()
+	return strings.Contains(msg, "database is locked") || strings.Contains(msg, "database table is locked") || strings.Contains(msg, "SQLITE_BUSY")
+}
+
+// openDatabaseOnce uma tentativa de openDatabase, com uma instância nova do Litestream
+func (dm *DatabaseManager) openDatabaseOnce(clientID, dbPath, s3Path string) (*litestream.DB, error) {
+	lsdb := litestream.NewDB(dbPath)
+	lsdb.Logger = dm.litestreamLogger(dbPath)
+	
+	// Ajustes por cliente em {clientID}.litestream.json (opcional)
+	overrides, err := loadReplicaOverrides(dbPath, clientID)
+	if err != nil {
+		log.Printf("⚠️  Ignoring invalid replica settings for client %s: %v", clientID, err)
+	}
+	
+	// Configura um replica S3 por bucket (-bucket ou o de -map do diretório)
+	for i, bucket := range dm.bucketsFor(dbPath) {
+		replica := litestream.NewReplica(lsdb, replicaName(dm.config.ReplicaType, i))
+		replica.Logger = dm.litestreamLogger(fmt.Sprintf("%s(%s)", dbPath, replica.Name()))
+		replica.Client = dm.replicaClients.NewReplicaClient(bucket, s3Path)
+		dm.configureReplica(replica, overrides)
+		lsdb.Replicas = append(lsdb.Replicas, replica)
+	}
+
+	if dm.config.RestoreOnCreate {
+		if err := dm.restoreOnCreate(lsdb.Replicas[0], dbPath); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := lsdb.Open(); err != nil {
+		return nil, fmt.Errorf("failed to open database %s: %v", dbPath, err)
+	}
+	return lsdb, nil
+}
+
+// restoreOnCreate (-restore-on-create) restaura o backup mais recente sobre um
+// banco ainda sem tabelas (ex: arquivo apagado e recriado pelo app), para que o
+// cliente recupere o histórico do S3 em vez de replicar um banco vazio
+func (dm *DatabaseManager) restoreOnCreate(replica *litestream.Replica, dbPath string) error {
+	if empty, err := isEmptyDatabase(dbPath, dm.config.OpenBusyTimeout); err != nil {
+		return err
+	} else if !empty {
+		return nil
+	}
+
+	tmpPath := dbPath + restoreTempSuffix
+	os.Remove(tmpPath)
+	defer os.Remove(tmpPath)
+
+	opt := litestream.NewRestoreOptions()
+	opt.OutputPath = tmpPath
+	opt.Logger = replica.Logger
+	generation, err := restoreWithRetry(dm.ctx, replica, opt, dm.config.RestoreTimeout)
+	if err != nil {
+		return fmt.Errorf("restore on create failed for %s: %w", dbPath, err)
+	} else if generation == "" {
+		return nil // cliente novo: nada no S3
+	}
+
+	if err := os.Rename(tmpPath, dbPath); err != nil {
+		return fmt.Errorf("restore on create failed for %s: %w", dbPath, err)
+	}
+	for _, suffix := range []string{"-wal", "-shm"} {
+		if err := os.Remove(dbPath + suffix); err != nil && !os.IsNotExist(err) {
+			log.Printf("⚠️  Failed to remove stale %s after restore on create: %v", dbPath+suffix, err)
+		}
+	}
+	logEvent(MsgRestoreOnCreate, dbPath, generation)
+	return nil
+}
+
+// sqliteURI DSN "file:" do go-sqlite3 com o caminho escapado: sem isso um "?"
+// ou "#" no nome do arquivo vira parâmetro e abre outro arquivo
+func sqliteURI(path string, params url.Values) string {
+	uri := "file:" + (&url.URL{Path: path}).EscapedPath()
+	if len(params) > 0 {
+		uri += "?" + params.Encode()
+	}
+	return uri
+}
+
+// isEmptyDatabase verifica se o banco não tem nenhuma tabela, índice ou view,
+// esperando até busyTimeout se outro processo estiver com o banco bloqueado
+func isEmptyDatabase(dbPath string, busyTimeout time.Duration) (bool, error) {
+	db, err := sql.Open("sqlite3", sqliteURI(dbPath, url.Values{"mode": {"ro"}, "_busy_timeout": {strconv.FormatInt(busyTimeout.Milliseconds(), 10)}}))
+	if err != nil {
+		return false, err
+	}
+	defer db.Close()
+
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM sqlite_master").Scan(&count); err != nil {
+		return false, fmt.Errorf("cannot read schema of %s: %w", dbPath, err)
+	}
+	return count == 0, nil
+}
+
+// pauseClient interrompe a replicação do cliente sem removê-lo (manutenção)
+func (dm *DatabaseManager) pauseClient(clientID string) error {
+	dm.mutex.Lock()
+	if dm.paused[clientID] {
+		dm.mutex.Unlock()
+		return fmt.Errorf("%w: client already paused: %s", errClientState, clientID)
+	}
+	lsdb, exists := dm.databases[clientID]
+	if !exists {
+		dm.mutex.Unlock()
+		return fmt.Errorf("%w: client is not replicating: %s", errClientState, clientID)
+	}
+	delete(dm.databases, clientID)
+	dm.paused[clientID] = true
+	config := dm.clients[clientID]
+	dm.mutex.Unlock()
+
+	// SoftClose para os replicas sem o sync final; roda fora do lock
+	if err := lsdb.SoftClose(); err != nil {
+		log.Printf("⚠️  Failed to close database for paused client %s: %v", clientID, err)
+	}
+	logEvent(MsgClientPaused, clientID)
+	dm.audit("paused", clientID, config.DatabasePath, config.S3Path)
+	return nil
+}
+
+// resumeClient reabre o banco do cliente pausado com novos replicas
+func (dm *DatabaseManager) resumeClient(clientID string) error {
+	dm.mutex.Lock()
+	config, exists := dm.clients[clientID]
+	if !exists || !dm.paused[clientID] {
+		dm.mutex.Unlock()
+		return fmt.Errorf("%w: client is not paused: %s", errClientState, clientID)
+	}
+	if dm.opening[clientID] != "" {
+		dm.mutex.Unlock()
+		return fmt.Errorf("%w: client is already resuming: %s", errClientState, clientID)
+	}
+	dm.opening[clientID] = config.DatabasePath
+	dm.mutex.Unlock()
+
+	defer func() {
+		dm.mutex.Lock()
+		delete(dm.opening, clientID)
+		dm.mutex.Unlock()
+	}()
+
+	// Ajustes do replica usam o ID extraído do arquivo (sem o sufixo de colisão)
+	lsdb, err := dm.openDatabase(dm.config.IDStrategy.Extract(config.DatabasePath), config.DatabasePath, config.S3Path)
+	if err != nil {
+		return err
+	}
+
+	dm.mutex.Lock()
+	defer dm.mutex.Unlock()
+
+	// Removido enquanto o banco era reaberto
+	if _, exists := dm.clients[clientID]; !exists {
+		go lsdb.Close()
+		return fmt.Errorf("%w: client was removed: %s", errClientState, clientID)
+	}
+
+	dm.databases[clientID] = lsdb
+	delete(dm.paused, clientID)
+	logEvent(MsgClientResumed, clientID)
+	dm.audit("resumed", clientID, config.DatabasePath, config.S3Path)
+	return nil
+}
+
+// RemovedClient resposta de DELETE /api/client/{clientID}
+type RemovedClient struct {
+	ClientID     string          `json:"clientId"`
+	DatabasePath string          `json:"databasePath"` // mantido no disco
+	S3Path       string          `json:"s3Path"`
+	WasPaused    bool            `json:"wasPaused"`
+	Purged       bool            `json:"purged"` // true quando todos os replicas foram apagados
+	Replicas     []PurgedReplica `json:"replicas,omitempty"`
+}
+
+// PurgedReplica resultado da limpeza de um replica com ?purge-s3=true
+type PurgedReplica struct {
+	Bucket      string   `json:"bucket"`
+	Path        string   `json:"path"`
+	Generations []string `json:"generations"` // gerações apagadas
+	Error       string   `json:"error,omitempty"`
+}
+
+// removeClient para de gerenciar o cliente sem apagar o arquivo (offboarding):
+// fecha o banco, remove o cliente dos mapas e ignora o arquivo nos próximos
+// scans. Com purgeS3, apaga também as gerações de todos os replicas.
+func (dm *DatabaseManager) removeClient(ctx context.Context, clientID string, purgeS3 bool) (*RemovedClient, error) {
+	dm.mutex.Lock()
+	config, exists := dm.clients[clientID]
+	if !exists {
+		dm.mutex.Unlock()
+		return nil, fmt.Errorf("%w: client was removed: %s", errClientState, clientID)
+	}
+	if dm.opening[clientID] != "" || dm.syncing[clientID] || dm.restoring[config.DatabasePath] {
+		dm.mutex.Unlock()
+		return nil, fmt.Errorf("%w: client is busy (registering, syncing or restoring): %s", errClientState, clientID)
+	}
+	removed := &RemovedClient{
+		ClientID:     clientID,
+		DatabasePath: config.DatabasePath,
+		S3Path:       config.S3Path,
+		WasPaused:    dm.paused[clientID],
+	}
+	dm.released[config.DatabasePath] = clientID
+	dm.mutex.Unlock()
+
+	// Sync final + SoftClose e remoção de todos os mapas
+	if err := dm.unregisterDatabase(config.DatabasePath); err != nil {
+		return nil, err
+	}
+	logEvent(MsgClientRemoved, clientID, config.DatabasePath)
+
+	if !purgeS3 {
+		return removed, nil
+	}
+	removed.Purged = true
+	for _, bucket := range dm.bucketsFor(config.DatabasePath) {
+		client := dm.replicaClients.NewReplicaClient(bucket, config.S3Path)
+		purged := PurgedReplica{Bucket: bucket, Path: config.S3Path, Generations: []string{}}
+		generations, err := client.Generations(ctx)
+		for _, generation := range generations {
+			if err != nil {
+				break
+			}
+			if err = client.DeleteGeneration(ctx, generation); err == nil {
+				purged.Generations = append(purged.Generations, generation)
+			}
+		}
+		if err != nil {
+			purged.Error = err.Error()
+			removed.Purged = false
+			log.Printf("⚠️  Failed to purge %s/ for removed client %s: %v", dm.config.replicaURL(bucket, config.S3Path), clientID, err)
+		} else {
+			logEvent(MsgClientPurged, clientID, dm.config.replicaURL(bucket, config.S3Path), len(purged.Generations))
+		}
+		removed.Replicas = append(removed.Replicas, purged)
+	}
+	return removed, nil
+}
+
+// SyncResult resposta de POST /api/client/{clientID}/sync
+type SyncResult struct {
+	ClientID  string        `json:"clientId"`
+	Position  string        `json:"position"` // posição local após o sync
+	Replicas  []ReplicaData `json:"replicas"`
+	ElapsedMs int64         `json:"elapsedMs"`
+}
+
+// syncClient envia imediatamente as escritas pendentes do cliente para todos os replicas
+func (dm *DatabaseManager) syncClient(ctx context.Context, clientID string) (*SyncResult, error) {
+	dm.mutex.Lock()
+	lsdb, exists := dm.databases[clientID]
+	if !exists {
+		dm.mutex.Unlock()
+		return nil, fmt.Errorf("%w: client is not replicating: %s", errClientState, clientID)
+	}
+	if dm.syncing[clientID] {
+		dm.mutex.Unlock()
+		return nil, fmt.Errorf("%w: sync already in progress: %s", errClientState, clientID)
+	}
+	dm.syncing[clientID] = true
+	dm.mutex.Unlock()
+
+	defer func() {
+		dm.mutex.Lock()
+		delete(dm.syncing, clientID)
+		dm.mutex.Unlock()
+	}()
+
+	start := time.Now()
+	if err := lsdb.Sync(ctx); err != nil {
+		return nil, fmt.Errorf("database sync failed: %w", err)
+	}
+	for _, replica := range lsdb.Replicas {
+		if err := replica.Sync(ctx); err != nil {
+			dm.events.Publish(ClientEvent{Event: "failed", ClientID: clientID, Error: err.Error(), Timestamp: time.Now().UTC()})
+			return nil, fmt.Errorf("replica %s sync failed: %w", replica.Name(), err)
+		}
+	}
+
+	elapsed := time.Since(start)
+	dm.observeSync(clientID, elapsed)
+	dm.events.Publish(ClientEvent{Event: "synced", ClientID: clientID, Timestamp: time.Now().UTC()})
+
+	pos, err := lsdb.Pos()
+	if err != nil {
+		return nil, fmt.Errorf("cannot read database position: %w", err)
+	}
+
+	return &SyncResult{
+		ClientID:  clientID,
+		Position:  pos.String(),
+		Replicas:  replicaData(lsdb),
+		ElapsedMs: elapsed.Milliseconds(),
+	}, nil
+}
+
+// restoreRetryAfter valor de Retry-After quando todas as vagas de restore estão ocupadas
+const restoreRetryAfter = 10 * time.Second
+
+// errRestoreDisabled restores recusados em nós somente de envio (-no-restore)
+var errRestoreDisabled = errors.New("restore is disabled on this node (-no-restore)")
+
+// Códigos do envelope de erro da API ({"error": {"code": ..., "message": ...}})
+const (
+	ErrCodeBadRequest       = "bad_request"
+	ErrCodeUnauthorized     = "unauthorized"
+	ErrCodeForbidden        = "forbidden"
+	ErrCodeNotFound         = "not_found"
+	ErrCodeClientNotFound   = "client_not_found"
+	ErrCodeMethodNotAllowed = "method_not_allowed"
+	ErrCodeConflict         = "conflict"
+	ErrCodeUnprocessable    = "unprocessable"
+	ErrCodeTooManyRequests  = "too_many_requests"
+	ErrCodeInternal         = "internal_error"
+	ErrCodeUpstream         = "upstream_error" // falha ao falar com o S3 (502)
+)
+
+// APIError envelope das respostas de erro em /api/*
+type APIError struct {
+	Error APIErrorBody `json:"error"`
+}
+
+// APIErrorBody código estável para os clientes da API e mensagem legível
+type APIErrorBody struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// writeAPIError responde com o envelope de erro JSON e o status informado
+func writeAPIError(w http.ResponseWriter, status int, code, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(APIError{Error: APIErrorBody{Code: code, Message: message}})
+}
+
+// errClientState operação não permitida no estado atual do cliente
+var errClientState = errors.New("invalid client state")
+
+// Tratamento de clientIDs repetidos em arquivos diferentes (-on-id-collision)
+const (
+	IDCollisionError  = "error"  // o segundo arquivo não é replicado
+	IDCollisionSuffix = "suffix" // o segundo arquivo usa o nome do diretório pai como sufixo
+)
+
+// idCollisionError o clientID já pertence a outro arquivo
+type idCollisionError struct {
+	ClientID     string
+	Path         string
+	ExistingPath string
+}
+
+func (e *idCollisionError) Error() string {
+	return fmt.Sprintf("client ID collision: %s is used by both %s and %s", e.ClientID, e.ExistingPath, e.Path)
+}
+
+// IDCollision arquivo não replicado porque o clientID já está em uso
+type IDCollision struct {
+	ClientID     string    `json:"clientId"`
+	DatabasePath string    `json:"databasePath"`
+	ExistingPath string    `json:"existingPath"`
+	DetectedAt   time.Time `json:"detectedAt"`
+}
+
+// recordCollision registra a colisão para o dashboard (com log apenas na primeira vez)
+func (dm *DatabaseManager) recordCollision(err *idCollisionError) {
+	dm.mutex.Lock()
+	defer dm.mutex.Unlock()
+
+	if _, exists := dm.collisions[err.Path]; exists {
+		return
+	}
+	dm.collisions[err.Path] = &IDCollision{
+		ClientID:     err.ClientID,
+		DatabasePath: err.Path,
+		ExistingPath: err.ExistingPath,
+		DetectedAt:   time.Now(),
+	}
+	logEvent(MsgIDCollision,
+		err.Path, err.ClientID, err.ExistingPath)
+}
+
+// idCollisions lista as colisões ordenadas por caminho (chamar com o lock)
+func (dm *DatabaseManager) idCollisions() []*IDCollision {
+	collisions := make([]*IDCollision, 0, len(dm.collisions))
+	for _, collision := range dm.collisions {
+		collisions = append(collisions, collision)
+	}
+	sort.Slice(collisions, func(i, j int) bool {
+		return collisions[i].DatabasePath < collisions[j].DatabasePath
+	})
+	return collisions
+}
+
+// errMaxClients limite de -max-clients atingido
+var errMaxClients = errors.New("max clients reached")
+
+// RejectedRegistration arquivo não replicado porque -max-clients foi atingido
+type RejectedRegistration struct {
+	ClientID     string    `json:"clientId"`
+	DatabasePath string    `json:"databasePath"`
+	RejectedAt   time.Time `json:"rejectedAt"`
+}
+
+// rejectedRegistrations lista os arquivos recusados ordenados por caminho (chamar com o lock)
+func (dm *DatabaseManager) rejectedRegistrations() []*RejectedRegistration {
+	rejected := make([]*RejectedRegistration, 0, len(dm.rejected))
+	for _, rejection := range dm.rejected {
+		rejected = append(rejected, rejection)
+	}
+	sort.Slice(rejected, func(i, j int) bool {
+		return rejected[i].DatabasePath < rejected[j].DatabasePath
+	})
+	return rejected
+}
+
+// errTooLarge arquivo maior que -max-db-size
+var errTooLarge = errors.New("database file too large")
+
+// SkippedFile arquivo não replicado por uma proteção (hoje, -max-db-size)
+type SkippedFile struct {
+	ClientID     string    `json:"clientId"`
+	DatabasePath string    `json:"databasePath"`
+	Reason       string    `json:"reason"`
+	Size         int64     `json:"size"`
+	DetectedAt   time.Time `json:"detectedAt"`
+}
+
+// skippedFiles lista os arquivos ignorados ordenados por caminho (chamar com o lock)
+func (dm *DatabaseManager) skippedFiles() []*SkippedFile {
+	skipped := make([]*SkippedFile, 0, len(dm.skipped))
+	for _, file := range dm.skipped {
+		skipped = append(skipped, file)
+	}
+	sort.Slice(skipped, func(i, j int) bool {
+		return skipped[i].DatabasePath < skipped[j].DatabasePath
+	})
+	return skipped
+}
+
+// checkDBSize recusa arquivos maiores que -max-db-size. O rescan confere de novo,
+// então um arquivo que diminuiu (ou foi trocado) passa a ser replicado.
+func (dm *DatabaseManager) checkDBSize(clientID, dbPath string) error {
+	limit := dm.config.MaxDBSize
+	if limit <= 0 {
+		return nil
+	}
+	info, err := os.Stat(dbPath)
+	if err != nil {
+		return &retriableError{err}
+	}
+
+	dm.mutex.Lock()
+	defer dm.mutex.Unlock()
+	if info.Size() <= limit {
+		delete(dm.skipped, dbPath)
+		return nil
+	}
+
+	reason := fmt.Sprintf("file is %s, larger than -max-db-size (%s)", formatBytes(info.Size()), formatBytes(limit))
+	if _, exists := dm.skipped[dbPath]; !exists {
+		logEvent(MsgTooLarge, dbPath, reason)
+	}
+	dm.skipped[dbPath] = &SkippedFile{
+		ClientID:     clientID,
+		DatabasePath: dbPath,
+		Reason:       reason,
+		Size:         info.Size(),
+		DetectedAt:   time.Now(),
+	}
+	return fmt.Errorf("%w: %s: %s", errTooLarge, dbPath, reason)
+}
+
+// reserveClient verifica se o cliente pode ser registrado e o marca como em abertura
+func (dm *DatabaseManager) reserveClient(clientID, dbPath string) error {
+	dm.mutex.Lock()
+	defer dm.mutex.Unlock()
+
+	// Verifica se path já está mapeado
+	if existingClientID, exists := dm.pathIndex[dbPath]; exists {
+		return fmt.Errorf("path already mapped to client: %s -> %s", dbPath, existingClientID)
+	}
+
+	// Verifica se cliente já existe (usar clientID como chave primária);
+	// o mesmo ID vindo de outro arquivo é uma colisão
+	existingPath := dm.opening[clientID]
+	if config, exists := dm.clients[clientID]; exists {
+		existingPath = config.DatabasePath
+	}
+	if existingPath == dbPath {
+		if _, registered := dm.clients[clientID]; !registered {
+			return fmt.Errorf("client registration already in progress: %s", clientID)
+		}
+		return fmt.Errorf("client already registered: %s", clientID)
+	} else if existingPath != "" {
+		return &idCollisionError{ClientID: clientID, Path: dbPath, ExistingPath: existingPath}
+	}
+
+	// -max-clients: conta os registrados e os que estão abrindo
+	if limit := dm.config.MaxClients; limit > 0 && len(dm.clients)+len(dm.opening) >= limit {
+		if _, exists := dm.rejected[dbPath]; !exists {
+			dm.rejected[dbPath] = &RejectedRegistration{
+				ClientID:     clientID,
+				DatabasePath: dbPath,
+				RejectedAt:   time.Now(),
+			}
+			log.Printf("⚠️  Max clients (%d) reached: %s is NOT being replicated", limit, dbPath)
+		}
+		return fmt.Errorf("%w (%d): %s", errMaxClients, limit, dbPath)
+	}
+	delete(dm.rejected, dbPath)
+
+	dm.opening[clientID] = dbPath
+	return nil
+}
+
+// configureReplica aplica as opções de retenção e intervalos ao replica
+// (antes de lsdb.Open), priorizando os ajustes do arquivo do cliente
+func (dm *DatabaseManager) configureReplica(replica *litestream.Replica, overrides replicaOverrides) {
+	replica.Retention = dm.config.Retention
+	replica.RetentionCheckInterval = dm.config.RetentionCheckInterval
+	replica.SnapshotInterval = dm.config.SnapshotInterval
+	replica.SyncInterval = dm.config.SyncInterval
+
+	if overrides.SnapshotInterval != nil {
+		replica.SnapshotInterval = *overrides.SnapshotInterval
+	}
+	if overrides.SyncInterval != nil {
+		replica.SyncInterval = *overrides.SyncInterval
+	}
+}
+
+// ReplicaSettings conteúdo de {clientID}.litestream.json, ao lado do banco.
+// Durações no formato do Go (ex: "500ms", "6h").
+type ReplicaSettings struct {
+	SnapshotInterval string `json:"snapshotInterval,omitempty"`
+	SyncInterval     string `json:"syncInterval,omitempty"`
+}
+
+// replicaOverrides ajustes já validados (nil = usa o valor global)
+type replicaOverrides struct {
+	SnapshotInterval *time.Duration
+	SyncInterval     *time.Duration
+}
+
+// loadReplicaOverrides lê o arquivo de ajustes do cliente, se existir
+func loadReplicaOverrides(dbPath, clientID string) (replicaOverrides, error) {
+	var overrides replicaOverrides
+
+	settingsPath := filepath.Join(filepath.Dir(dbPath), clientID+".litestream.json")
+	data, err := os.ReadFile(settingsPath)
+	if os.IsNotExist(err) {
+		return overrides, nil
+	} else if err != nil {
+		return overrides, err
+	}
+
+	var settings ReplicaSettings
+	if err := json.Unmarshal(data, &settings); err != nil {
+		return overrides, fmt.Errorf("%s: %w", settingsPath, err)
+	}
+
+	if settings.SnapshotInterval != "" {
+		d, err := time.ParseDuration(settings.SnapshotInterval)
+		if err != nil || d < 0 {
+			return replicaOverrides{}, fmt.Errorf("%s: invalid snapshotInterval %q", settingsPath, settings.SnapshotInterval)
+		}
+		overrides.SnapshotInterval = &d
+	}
+	if settings.SyncInterval != "" {
+		d, err := time.ParseDuration(settings.SyncInterval)
+		if err != nil || d <= 0 {
+			return replicaOverrides{}, fmt.Errorf("%s: invalid syncInterval %q", settingsPath, settings.SyncInterval)
+		}
+		overrides.SyncInterval = &d
+	}
+
+	log.Printf("⚙️  Client %s uses replica settings from %s", clientID, settingsPath)
+	return overrides, nil
+}
+
+// queueRetry agenda nova tentativa de registro com backoff exponencial se a
+// falha for transitória. Retorna true se o erro foi tratado pela fila.
+func (dm *DatabaseManager) queueRetry(dbPath string, err error) bool {
+	var retriable *retriableError
+	if !errors.As(err, &retriable) {
+		return false
+	}
+
+	dm.mutex.Lock()
+	defer dm.mutex.Unlock()
+
+	state, exists := dm.retries[dbPath]
+	if !exists {
+		state = &retryState{}
+		dm.retries[dbPath] = state
+	}
+	state.attempts++
+
+	if state.attempts > dm.config.RegisterMaxRetries {
+		delete(dm.retries, dbPath)
+		dm.failed[dbPath] = &FailedRegistration{
+			ClientID:     dm.config.IDStrategy.Extract(dbPath),
+			DatabasePath: dbPath,
+			Attempts:     state.attempts,
+			Error:        err.Error(),
+			FailedAt:     time.Now(),
+		}
+		logEvent(MsgRegistrationFailed, state.attempts, dbPath, err)
+		return true
+	}
+
+	delay := retryDelay(state.attempts)
+	state.nextAttempt = time.Now().Add(delay)
+	logEvent(MsgRegistrationRetry,
+		delay, state.attempts, dm.config.RegisterMaxRetries, err)
+	return true
+}
+
+// retryDelay backoff exponencial a partir de 1s, limitado a 5 minutos
+func retryDelay(attempts int) time.Duration {
+	delay := time.Second << uint(attempts-1)
+	if delay <= 0 || delay > 5*time.Minute {
+		return 5 * time.Minute
+	}
+	return delay
+}
+
+// processRetries executa as tentativas de registro agendadas quando vencem
+func (dm *DatabaseManager) processRetries() {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-dm.ctx.Done():
+			return
+		case now := <-ticker.C:
+			dm.mutex.Lock()
+			due := make(map[string]int)
+			for dbPath, state := range dm.retries {
+				if !now.Before(state.nextAttempt) {
+					due[dbPath] = state.attempts
+				}
+			}
+			dm.mutex.Unlock()
+
+			for dbPath, attempts := range due {
+				// Arquivo removido desde a última tentativa
+				if _, err := os.Stat(dbPath); err != nil {
+					dm.mutex.Lock()
+					delete(dm.retries, dbPath)
+					dm.mutex.Unlock()
+					continue
+				}
+
+				if err := dm.registerDatabase(dbPath); err != nil {
+					if !dm.queueRetry(dbPath, err) {
+						// Erro definitivo (ex: registrado por outro caminho)
+						dm.mutex.Lock()
+						delete(dm.retries, dbPath)
+						dm.mutex.Unlock()
+					}
+					continue
+				}
+				logEvent(MsgRegistrationRecovered, attempts, dbPath)
+			}
+		}
+	}
+}
+
+// unregisterSyncTimeout limite do sync final ao remover um cliente
+const unregisterSyncTimeout = 5 * time.Second
+
+// finalSync envia ao S3 o que ainda não foi replicado, antes de fechar o banco
+func (dm *DatabaseManager) finalSync(ctx context.Context, clientID string, lsdb *litestream.DB) error {
+	start := time.Now()
+	err := lsdb.Sync(ctx)
+	for _, replica := range lsdb.Replicas {
+		if err != nil {
+			break
+		}
+		err = replica.Sync(ctx)
+	}
+	if err == nil {
+		dm.observeSync(clientID, time.Since(start))
+	}
+	return err
+}
+
+// isClosedError erro de um banco que já foi fechado (esperado ao remover
+// um cliente durante um sync em andamento)
+func isClosedError(err error) bool {
+	if err == nil {
+		return false
+	}
+	msg := err.Error()
+	return strings.Contains(msg, "database closed") || strings.Contains(msg, "database is closed")
+}
+
+// closeDatabase faz um último sync com tempo limitado e fecha o banco com
+// SoftClose, o mesmo caminho usado no encerramento (Stop). Falhas são só
+// registradas: o arquivo pode já ter sido apagado.
+func (dm *DatabaseManager) closeDatabase(clientID string, lsdb *litestream.DB) {
+	ctx, cancel := context.WithTimeout(dm.ctx, unregisterSyncTimeout)
+	defer cancel()
+
+	if err := dm.finalSync(ctx, clientID, lsdb); err != nil {
+		switch {
+		case isClosedError(err):
+			debugf("Final sync skipped for client %s: database already closed", clientID)
+		case ctx.Err() != nil:
+			log.Printf("⏱️  Final sync timed out: %s", clientID)
+		default:
+			log.Printf("⚠️  Final sync failed for client %s (best effort): %v", clientID, err)
+		}
+	}
+
+	if err := lsdb.SoftClose(); err != nil && !isClosedError(err) {
+		log.Printf("⚠️  Failed to close database for client %s: %v", clientID, err)
+	}
+}
+
+// unregisterDatabase remove cliente (1:1 otimizado) 
+func (dm *DatabaseManager) unregisterDatabase(dbPath string) error {
+	dm.mutex.Lock()
+
+	// Arquivo removido: descarta tentativas pendentes de registro
+	delete(dm.retries, dbPath)
+	delete(dm.failed, dbPath)
+	delete(dm.collisions, dbPath)
+	delete(dm.rejected, dbPath)
+	delete(dm.skipped, dbPath)
+	delete(dm.problems, dbPath)
+
+	// Lookup otimizado via pathIndex
+	clientID, exists := dm.pathIndex[dbPath]
+	if !exists {
+		dm.mutex.Unlock()
+		return nil // Silencioso se não existe
+	}
+
+	// Arquivos que colidiam com este cliente agora podem ser registrados
+	var freed []string
+	for path, collision := range dm.collisions {
+		if collision.ClientID == clientID {
+			freed = append(freed, path)
+			delete(dm.collisions, path)
+		}
+	}
+
+	lsdb, dbExists := dm.databases[clientID] // O(1) lookup
+	var s3Path string
+	if config, ok := dm.clients[clientID]; ok {
+		s3Path = config.S3Path
+	}
+	
+	// Remove de todos os mapas
+	delete(dm.databases, clientID)
+	delete(dm.clients, clientID)
+	delete(dm.pathIndex, dbPath)
+	delete(dm.syncFailures, clientID)
+	delete(dm.paused, clientID)
+	dm.mutex.Unlock()
+
+	if dbExists {
+		// Para replicação (sync final + SoftClose, fora do lock)
+		dm.closeDatabase(clientID, lsdb)
+		unregistrationsTotal.Inc()
+	}
+
+	dm.dropStats(clientID)
+	logEvent(MsgClientUnregistered, clientID)
+	dm.audit("unregistered", clientID, dbPath, s3Path)
+
+	for _, path := range freed {
+		if err := dm.registerDatabase(path); err != nil && !dm.queueRetry(path, err) {
+			log.Printf("⚠️  Failed to register database %s: %v", path, err)
+		}
+	}
+
+	return nil
+}
+
+// runOnce (-once) registra os bancos dos watch dirs, força o sync completo de
+// cada um e retorna erro se algum não pôde ser registrado ou enviado ao S3.
+// O sync final e o fechamento ficam com Stop.
+func (dm *DatabaseManager) runOnce() error {
+	if err := dm.scanExistingDatabases(); err != nil {
+		return err
+	}
+
+	// Arquivos encontrados mas não replicados (um mesmo path pode estar em mais de um mapa)
+	dm.mutex.RLock()
+	clientIDs := make([]string, 0, len(dm.databases))
+	for clientID := range dm.databases {
+		clientIDs = append(clientIDs, clientID)
+	}
+	unregistered := make(map[string]bool)
+	for path := range dm.retries {
+		unregistered[path] = true
+	}
+	for path := range dm.failed {
+		unregistered[path] = true
+	}
+	for path := range dm.collisions {
+		unregistered[path] = true
+	}
+	for path := range dm.rejected {
+		unregistered[path] = true
+	}
+	for path := range dm.skipped {
+		unregistered[path] = true
+	}
+	for path, problem := range dm.problems {
+		if problem.Kind == ProblemOpenError {
+			unregistered[path] = true
+		}
+	}
+	dm.mutex.RUnlock()
+	sort.Strings(clientIDs)
+
+	for path := range unregistered {
+		log.Printf("⚠️  Not replicated: %s", path)
+	}
+
+	var synced, failed int
+	for _, clientID := range clientIDs {
+		result, err := dm.syncClient(dm.ctx, clientID)
+		if err != nil {
+			log.Printf("⚠️  Sync failed for client %s: %v", clientID, err)
+			failed++
+			continue
+		}
+		log.Printf("🔄 Synced %s (%dms)", clientID, result.ElapsedMs)
+		synced++
+	}
+
+	log.Printf("🏁 Once: %d synced, %d sync failures, %d not registered", synced, failed, len(unregistered))
+	if failed > 0 || len(unregistered) > 0 {
+		return fmt.Errorf("%d database(s) failed to sync and %d could not be registered", failed, len(unregistered))
+	}
+	return nil
+}
+
+// scanExistingDatabases escaneia bancos existentes
+func (dm *DatabaseManager) scanExistingDatabases() error {
+	waiting := make(map[string]bool)
+	for _, dir := range dm.waitingDirList() {
+		waiting[dir] = true
+	}
+	
+	for _, watchDir := range dm.watchDirs {
+		if waiting[watchDir] {
+			continue // será escaneado quando for criado
+		}
+		dm.scanDirectory(watchDir)
+	}
+	
+	dm.mutex.RLock()
+	clientCount := len(dm.databases)
+	dm.mutex.RUnlock()
+	
+	log.Printf("🎯 Monitoring %d clients across %d directories", clientCount, len(dm.watchDirs))
+	return nil
+}
+
+
+
+// Tentativas de restore durante o registro (-restore-timeout vale para cada uma)
+const (
+	restoreMaxAttempts = 3
+	restoreRetryDelay  = 2 * time.Second // dobra a cada nova tentativa
+)
+
+// restoreWithRetry executa restoreReplica com timeout por tentativa, repetindo
+// falhas como conexões S3 travadas ou interrompidas. Desiste quando ctx é
+// cancelado (encerramento) e devolve o último erro após restoreMaxAttempts.
+func restoreWithRetry(ctx context.Context, replica *litestream.Replica, opt litestream.RestoreOptions, timeout time.Duration) (string, error) {
+	delay := restoreRetryDelay
+	for attempt := 1; ; attempt++ {
+		attemptCtx, cancel := ctx, context.CancelFunc(func() {})
+		if timeout > 0 {
+			attemptCtx, cancel = context.WithTimeout(ctx, timeout)
+		}
+		generation, err := restoreReplica(attemptCtx, replica, opt)
+		timedOut := attemptCtx.Err() == context.DeadlineExceeded
+		cancel()
+		if err == nil {
+			return generation, nil
+		}
+		if timedOut {
+			err = fmt.Errorf("restore timed out after %s (-restore-timeout): %w", timeout, err)
+		}
+		if ctx.Err() != nil || attempt == restoreMaxAttempts {
+			return "", err
+		}
+
+		// Arquivo parcial da tentativa interrompida
+		os.Remove(opt.OutputPath + ".tmp")
+		log.Printf("⚠️  Restore of %s failed (attempt %d/%d), retrying in %s: %v", replica.DB().Path(), attempt, restoreMaxAttempts, delay, err)
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+// restoreReplica restores replica to opt.OutputPath. When opt.Generation is
+// empty the latest generation (or the one covering opt.Timestamp) is used.
+// It returns the restored generation, or "" if the replica has none.
+func restoreReplica(ctx context.Context, replica *litestream.Replica, opt litestream.RestoreOptions) (string, error) {
+	// Determine the generation to restore from.
+	if opt.Generation == "" {
+		generation, _, err := replica.CalcRestoreTarget(ctx, opt)
+		if err != nil {
+			return "", err
+		} else if generation == "" {
+			return "", nil
+		}
+		opt.Generation = generation
+	}
+
+	log.Printf("♻️  Restoring %s from generation %s", opt.OutputPath, opt.Generation)
+	if err := replica.Restore(ctx, opt); err != nil {
+		return "", err
+	}
+	debugf("Restore of generation %s complete: %s", opt.Generation, opt.OutputPath)
+	return opt.Generation, nil
+}
+
+// ClientBackup backups de um cliente no S3 (subcomando list)
+type ClientBackup struct {
+	ClientID    string             `json:"clientId"`
+	Path        string             `json:"path"`
+	Generations []GenerationBackup `json:"generations"`
+	Error       string             `json:"error,omitempty"`
+}
+
+// GenerationBackup geração de um cliente e seu snapshot mais recente
+type GenerationBackup struct {
+	ID             string     `json:"id"`
+	Snapshots      int        `json:"snapshots"`
+	LatestSnapshot *time.Time `json:"latestSnapshot,omitempty"`
+}
+
+// listClientBackup lê as gerações de um cliente e o horário do snapshot mais
+// recente de cada uma, usando a API de replica client do Litestream
+func listClientBackup(ctx context.Context, client litestream.ReplicaClient) ([]GenerationBackup, error) {
+	ids, err := client.Generations(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list generations: %w", err)
+	}
+
+	generations := make([]GenerationBackup, 0, len(ids))
+	for _, id := range ids {
+		generation := GenerationBackup{ID: id}
+
+		itr, err := client.Snapshots(ctx, id)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list snapshots of generation %s: %w", id, err)
+		}
+		for itr.Next() {
+			createdAt := itr.Snapshot().CreatedAt
+			generation.Snapshots++
+			if generation.LatestSnapshot == nil || createdAt.After(*generation.LatestSnapshot) {
+				generation.LatestSnapshot = &createdAt
+			}
+		}
+		err = itr.Err()
+		itr.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to list snapshots of generation %s: %w", id, err)
+		}
+
+		generations = append(generations, generation)
+	}
+	return generations, nil
+}
+
+// runListCommand subcomando "list": lista os clientes com backup no bucket,
+// suas gerações e o último snapshot, sem precisar dos arquivos locais
+func runListCommand(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("list", flag.ContinueOnError)
+	bucket := fs.String("bucket", "", "bucket, Azure container or, with -replica-type file, root directory to inspect")
+	clientID := fs.String("client", "", "only list this client")
+	prefix := fs.String("prefix", "databases", "prefix holding one directory per client (the static part of -s3-path-template)")
+	jsonOutput := fs.Bool("json", false, "print JSON instead of a table")
+	backend := addReplicaFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *bucket == "" {
+		fs.Usage()
+		return fmt.Errorf("required: -bucket NAME")
+	}
+	roots := []string{*bucket}
+	config, err := backend.config(roots, nil)
+	if err != nil {
+		return err
+	}
+	*bucket = roots[0]
+
+	ids := []string{*clientID}
+	if *clientID == "" {
+		if ids, err = config.listClientIDs(ctx, *bucket, *prefix); err != nil {
+			return fmt.Errorf("failed to list clients in bucket %s: %w", *bucket, err)
+		}
+	}
+
+	backups := make([]ClientBackup, 0, len(ids))
+	for _, id := range ids {
+		backup := ClientBackup{ClientID: id, Path: strings.Trim(*prefix, "/") + "/" + id}
+		generations, err := listClientBackup(ctx, config.NewReplicaClient(*bucket, backup.Path))
+		if err != nil {
+			backup.Error = err.Error()
+		}
+		backup.Generations = generations
+		backups = append(backups, backup)
+	}
+
+	if *jsonOutput {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(backups)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "CLIENT\tGENERATION\tSNAPSHOTS\tLATEST SNAPSHOT")
+	for _, backup := range backups {
+		switch {
+		case backup.Error != "":
+			fmt.Fprintf(w, "%s\t-\t-\terror: %s\n", backup.ClientID, backup.Error)
+		case len(backup.Generations) == 0:
+			fmt.Fprintf(w, "%s\t-\t0\t-\n", backup.ClientID)
+		}
+		for _, generation := range backup.Generations {
+			latest := "-"
+			if generation.LatestSnapshot != nil {
+				latest = formatTime(generation.LatestSnapshot)
+			}
+			fmt.Fprintf(w, "%s\t%s\t%d\t%s\n", backup.ClientID, generation.ID, generation.Snapshots, latest)
+		}
+	}
+	return w.Flush()
+}
+
+// runRestoreCommand subcomando "restore": restaura um único cliente do S3 em
+// um arquivo local, sem iniciar o watcher nem o servidor de status
+func runRestoreCommand(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("restore", flag.ContinueOnError)
+	bucket := fs.String("bucket", "", "bucket, Azure container or, with -replica-type file, root directory holding the client's replica")
+	clientID := fs.String("client", "", "id of the client to restore")
+	output := fs.String("o", "", "path of the restored database (must not exist)")
+	generation := fs.String("generation", "", "generation to restore (default: latest, or the one covering -timestamp)")
+	timestamp := fs.String("timestamp", "", "restore the state at this time (RFC3339, e.g. 2024-01-02T15:04:05Z)")
+	pathTemplate := fs.String("s3-path-template", DefaultPathTemplate, "Go template for the replica path, as used by the manager")
+	env := fs.String("env", "", "environment name available as {{.Env}} in -s3-path-template")
+	backend := addReplicaFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *bucket == "" || *clientID == "" || *output == "" {
+		fs.Usage()
+		return fmt.Errorf("required: -bucket NAME -client ID -o PATH")
+	}
+	roots := []string{*bucket}
+	config, err := backend.config(roots, nil)
+	if err != nil {
+		return err
+	}
+	*bucket = roots[0]
+	if _, err := os.Stat(*output); err == nil {
+		return fmt.Errorf("output file already exists: %s", *output)
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	opt := litestream.NewRestoreOptions()
+	opt.OutputPath = *output
+	opt.Generation = *generation
+	opt.Logger = log.New(os.Stderr, "", log.LstdFlags|log.Lmicroseconds)
+	if *timestamp != "" {
+		ts, err := time.Parse(time.RFC3339, *timestamp)
+		if err != nil {
+			return fmt.Errorf("invalid -timestamp %q: expected RFC3339 such as \"2024-01-02T15:04:05Z\"", *timestamp)
+		}
+		opt.Timestamp = ts
+	}
+
+	tmpl, err := parsePathTemplate(*pathTemplate)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	s3Path, err := renderPath(tmpl, PathTemplateData{
+		ClientID: *clientID,
+		Env:      *env,
+		Year:     now.Format("2006"),
+		Month:    now.Format("01"),
+		Day:      now.Format("02"),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to render replica path for client %s: %w", *clientID, err)
+	}
+
+	replica := litestream.NewReplica(litestream.NewDB(*output), replicaName(config.ReplicaType, 0))
+	replica.Client = config.NewReplicaClient(*bucket, s3Path)
+
+	if err := os.MkdirAll(filepath.Dir(*output), 0755); err != nil {
+		return err
+	}
+
+	log.Printf("📥 Restoring client %s from %s/ to %s", *clientID, config.replicaURL(*bucket, s3Path), *output)
+	restored, err := restoreReplica(ctx, replica, opt)
+	if err != nil {
+		return fmt.Errorf("restore failed: %w", err)
+	} else if restored == "" && !opt.Timestamp.IsZero() {
+		return fmt.Errorf("no backup of client %s at or before %s", *clientID, opt.Timestamp.Format(time.RFC3339))
+	} else if restored == "" {
+		return fmt.Errorf("no backups available for client %s", *clientID)
+	}
+
+	log.Printf("✅ Restored client %s (generation %s) to %s", *clientID, restored, *output)
+	return nil
+}
+
+
+
+// RestoreRequest corpo de POST /api/client/{clientID}/restore
+type RestoreRequest struct {
+	Generation string `json:"generation"` // vazio = geração mais recente
+	Timestamp  string `json:"timestamp"`  // RFC3339; vazio = último estado disponível
+	OutputPath string `json:"outputPath"` // vazio = arquivo novo no diretório temporário
+	Force      bool   `json:"force"`      // sobrescreve outputPath se já existir
+	Mode       string `json:"mode"`       // "copy" (padrão) ou "in-place" (substitui o banco do cliente)
+}
+
+// RestoreModeInPlace restore sobre o próprio banco do cliente
+const RestoreModeInPlace = "in-place"
+
+// RestoreEvent linha NDJSON transmitida durante o restore
+type RestoreEvent struct {
+	Type       string `json:"type"` // "progress", "result" ou "error"
+	Message    string `json:"message,omitempty"`
+	Generation string `json:"generation,omitempty"`
+	OutputPath string `json:"outputPath,omitempty"`
+	Timestamp  string `json:"timestamp,omitempty"` // ponto no tempo pedido (RFC3339)
+	Bytes      int64  `json:"bytes,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// restoreStream transmite eventos do restore como NDJSON, com flush a cada linha.
+// Implementa io.Writer para receber o log do Litestream como progresso.
+type restoreStream struct {
+	mu      sync.Mutex
+	w       http.ResponseWriter
+	encoder *json.Encoder
+}
+
+func newRestoreStream(w http.ResponseWriter) *restoreStream {
+	return &restoreStream{w: w, encoder: json.NewEncoder(w)}
+}
+
+func (rs *restoreStream) send(event RestoreEvent) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	rs.encoder.Encode(event)
+	if flusher, ok := rs.w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (rs *restoreStream) Write(p []byte) (int, error) {
+	rs.send(RestoreEvent{Type: "progress", Message: strings.TrimRight(string(p), "\n")})
+	return len(p), nil
+}
+
+// restoreClient restaura o backup do cliente a partir do replica principal
+// para opt.OutputPath, resolvendo a geração mais recente se nenhuma for informada
+func (dm *DatabaseManager) restoreClient(ctx context.Context, clientID string, opt litestream.RestoreOptions) (litestream.RestoreOptions, error) {
+	if dm.config.NoRestore {
+		return opt, errRestoreDisabled
+	}
+
+	dm.mutex.RLock()
+	lsdb, exists := dm.databases[clientID]
+	var s3Path string
+	if config, ok := dm.clients[clientID]; ok {
+		s3Path = config.S3Path
+	}
+	dm.mutex.RUnlock()
+
+	if !exists || len(lsdb.Replicas) == 0 {
+		return opt, fmt.Errorf("client has no active replica: %s", clientID)
+	}
+	replica := lsdb.Replicas[0]
+
+	if opt.Generation == "" {
+		generation, _, err := replica.CalcRestoreTarget(ctx, opt)
+		if err != nil {
+			return opt, fmt.Errorf("cannot determine restore target: %w", err)
+		}
+		if generation == "" && !opt.Timestamp.IsZero() {
+			return opt, fmt.Errorf("no backup of client %s at or before %s", clientID, opt.Timestamp.Format(time.RFC3339))
+		} else if generation == "" {
+			return opt, fmt.Errorf("no backups available for client %s", clientID)
+		}
+		opt.Generation = generation
+	}
+
+	if err := os.MkdirAll(filepath.Dir(opt.OutputPath), 0755); err != nil {
+		return opt, err
+	}
+
+	if err := replica.Restore(ctx, opt); err != nil {
+		return opt, err
+	}
+	dm.audit("restore", clientID, opt.OutputPath, s3Path)
+	return opt, nil
+}
+
+// VerifyResult resposta de POST /api/client/{clientID}/verify
+type VerifyResult struct {
+	ClientID       string `json:"clientId"`
+	Generation     string `json:"generation"`
+	OK             bool   `json:"ok"`
+	IntegrityCheck string `json:"integrityCheck"` // "ok" ou as mensagens do PRAGMA integrity_check
+	Table          string `json:"table,omitempty"`
+	RowCount       *int64 `json:"rowCount,omitempty"`
+	Bytes          int64  `json:"bytes"`
+	ElapsedMs      int64  `json:"elapsedMs"`
+}
+
+// sqlIdentifier nomes de tabela aceitos para a contagem de linhas da verificação
+var sqlIdentifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// verifyClient restaura o backup mais recente em um diretório temporário, roda
+// PRAGMA integrity_check e, se informado, conta as linhas de uma tabela
+func (dm *DatabaseManager) verifyClient(ctx context.Context, clientID, table string) (*VerifyResult, error) {
+	if table != "" && !sqlIdentifier.MatchString(table) {
+		return nil, fmt.Errorf("invalid table name: %q", table)
+	}
+
+	dir, err := os.MkdirTemp("", "litestream-verify-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	start := time.Now()
+	opt := litestream.NewRestoreOptions()
+	opt.OutputPath = filepath.Join(dir, clientID+".db")
+	opt.Logger = log.New(io.Discard, "", 0)
+	opt, err = dm.restoreClient(ctx, clientID, opt)
+	if err != nil {
+		return nil, fmt.Errorf("restore failed: %w", err)
+	}
+
+	result := &VerifyResult{ClientID: clientID, Generation: opt.Generation, Table: table}
+	if info, err := os.Stat(opt.OutputPath); err == nil {
+		result.Bytes = info.Size()
+	}
+
+	db, err := sql.Open("sqlite3", sqliteURI(opt.OutputPath, nil))
+	if err != nil {
+		return nil, fmt.Errorf("cannot open restored database: %w", err)
+	}
+	defer db.Close()
+
+	result.IntegrityCheck, result.OK, err = integrityCheck(ctx, db)
+	if err != nil {
+		return nil, fmt.Errorf("integrity check failed: %w", err)
+	}
+
+	if table != "" {
+		var count int64
+		if err := db.QueryRowContext(ctx, fmt.Sprintf(`SELECT COUNT(*) FROM "%s"`, table)).Scan(&count); err != nil {
+			return nil, fmt.Errorf("cannot count rows of %s: %w", table, err)
+		}
+		result.RowCount = &count
+	}
+
+	result.ElapsedMs = time.Since(start).Milliseconds()
+	return result, nil
+}
+
+// integrityCheck roda PRAGMA integrity_check; ok é true somente se o resultado for "ok"
+func integrityCheck(ctx context.Context, db *sql.DB) (string, bool, error) {
+	rows, err := db.QueryContext(ctx, "PRAGMA integrity_check")
+	if err != nil {
+		return "", false, err
+	}
+	defer rows.Close()
+
+	var messages []string
+	for rows.Next() {
+		var msg string
+		if err := rows.Scan(&msg); err != nil {
+			return "", false, err
+		}
+		messages = append(messages, msg)
+	}
+	if err := rows.Err(); err != nil {
+		return "", false, err
+	}
+	return strings.Join(messages, "; "), len(messages) == 1 && messages[0] == "ok", nil
+}
+
+// restoreInPlace restaura o backup do cliente sobre o próprio banco: grava em um
+// arquivo temporário ao lado, confere a integridade, remove o cliente (sync final
+// e fechamento dos handles do Litestream), troca o arquivo com um rename atômico
+// e registra o cliente de novo. O watcher ignora o arquivo durante a troca.
+func (dm *DatabaseManager) restoreInPlace(ctx context.Context, clientID string, opt litestream.RestoreOptions) (litestream.RestoreOptions, error) {
+	dm.mutex.Lock()
+	config, exists := dm.clients[clientID]
+	if !exists {
+		dm.mutex.Unlock()
+		return opt, fmt.Errorf("%w: client is not registered: %s", errClientState, clientID)
+	}
+	dbPath := config.DatabasePath
+	if dm.restoring[dbPath] {
+		dm.mutex.Unlock()
+		return opt, fmt.Errorf("%w: in-place restore already in progress: %s", errClientState, clientID)
+	}
+	dm.restoring[dbPath] = true
+	dm.mutex.Unlock()
+
+	defer func() {
+		dm.mutex.Lock()
+		delete(dm.restoring, dbPath)
+		dm.mutex.Unlock()
+	}()
+
+	// Mesmo diretório do banco para que o rename seja atômico
+	tmpPath := dbPath + restoreTempSuffix
+	os.Remove(tmpPath)
+	defer os.Remove(tmpPath)
+
+	opt.OutputPath = tmpPath
+	opt, err := dm.restoreClient(ctx, clientID, opt)
+	if err != nil {
+		return opt, err
+	}
+
+	db, err := sql.Open("sqlite3", sqliteURI(tmpPath, nil))
+	if err != nil {
+		return opt, fmt.Errorf("cannot open restored database: %w", err)
+	}
+	result, ok, err := integrityCheck(ctx, db)
+	db.Close()
+	if err != nil {
+		return opt, fmt.Errorf("integrity check failed: %w", err)
+	} else if !ok {
+		return opt, fmt.Errorf("restored database failed integrity check: %s", result)
+	}
+
+	if err := dm.unregisterDatabase(dbPath); err != nil {
+		return opt, err
+	}
+
+	if err := os.Rename(tmpPath, dbPath); err != nil {
+		// O banco original continua no lugar: volta a replicá-lo
+		if regErr := dm.registerDatabase(dbPath); regErr != nil {
+			dm.queueRetry(dbPath, regErr)
+		}
+		return opt, fmt.Errorf("cannot replace %s: %w", dbPath, err)
+	}
+	opt.OutputPath = dbPath
+
+	// WAL e SHM do banco antigo seriam aplicados sobre o restaurado
+	for _, suffix := range []string{"-wal", "-shm"} {
+		if err := os.Remove(dbPath + suffix); err != nil && !os.IsNotExist(err) {
+			log.Printf("⚠️  Failed to remove stale %s after in-place restore: %v", dbPath+suffix, err)
+		}
+	}
+
+	if err := dm.registerDatabase(dbPath); err != nil && !dm.queueRetry(dbPath, err) {
+		return opt, fmt.Errorf("database restored but registration failed: %w", err)
+	}
+	return opt, nil
+}
+
+// resolveRestoreOutput limpa o outputPath de um restore e o confina em
+// -restore-dir (caminhos relativos são resolvidos a partir dele); nunca aponta
+// para um watch dir nem para o banco de um cliente, que force:true apagaria
+func (dm *DatabaseManager) resolveRestoreOutput(outputPath string) (string, error) {
+	root := dm.config.RestoreDir
+	if root == "" {
+		root = os.TempDir()
+	}
+	path := filepath.Clean(outputPath)
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(root, path)
+	}
+	// Symlinks no diretório pai não podem levar o arquivo para fora de root
+	if dir, err := filepath.EvalSymlinks(filepath.Dir(path)); err == nil {
+		path = filepath.Join(dir, filepath.Base(path))
+	}
+	if path == root || !isWithinDir(root, path) {
+		return "", fmt.Errorf("must be a file inside the restore directory %s", root)
+	}
+	for _, dir := range dm.watchDirs {
+		if isWithinDir(dir, path) {
+			return "", fmt.Errorf("must not be inside watch dir %s", dir)
+		}
+	}
+
+	dm.mutex.RLock()
+	defer dm.mutex.RUnlock()
+	for clientID, config := range dm.clients {
+		if config.DatabasePath == path {
+			return "", fmt.Errorf("is the database of client %s", clientID)
+		}
+	}
+	return path, nil
+}
+
+// handleClientRestore executa POST /api/client/{clientID}/restore transmitindo o progresso
+func handleClientRestore(dm *DatabaseManager, w http.ResponseWriter, r *http.Request, clientID string) {
+	var req RestoreRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		writeAPIError(w, http.StatusBadRequest, ErrCodeBadRequest, fmt.Sprintf("Invalid JSON body: %v", err))
+		return
+	}
+
+	inPlace := req.Mode == RestoreModeInPlace
+	if req.Mode != "" && req.Mode != "copy" && !inPlace {
+		writeAPIError(w, http.StatusBadRequest, ErrCodeBadRequest, fmt.Sprintf("Invalid mode %q: must be copy or in-place", req.Mode))
+		return
+	}
+	if inPlace && (req.OutputPath != "" || req.Force) {
+		writeAPIError(w, http.StatusBadRequest, ErrCodeBadRequest, "outputPath and force cannot be used with mode in-place")
+		return
+	}
+
+	opt := litestream.NewRestoreOptions()
+	opt.Generation = req.Generation
+	if req.Timestamp != "" {
+		// Restaura até o último WAL igual ou anterior ao timestamp
+		timestamp, err := time.Parse(time.RFC3339, req.Timestamp)
+		if err != nil {
+			writeAPIError(w, http.StatusBadRequest, ErrCodeBadRequest, fmt.Sprintf("Invalid timestamp %q: expected RFC3339 such as \"2024-01-02T15:04:05Z\" or \"2024-01-02T12:04:05-03:00\"", req.Timestamp))
+			return
+		}
+		if timestamp.After(time.Now()) {
+			writeAPIError(w, http.StatusBadRequest, ErrCodeBadRequest, fmt.Sprintf("Invalid timestamp %q: it is in the future", req.Timestamp))
+			return
+		}
+		opt.Timestamp = timestamp
+	}
+
+	opt.OutputPath = req.OutputPath
+	if inPlace {
+		streamClientRestore(dm, w, r, clientID, opt, req.Timestamp, dm.restoreInPlace)
+		return
+	}
+	if opt.OutputPath == "" {
+		opt.OutputPath = fmt.Sprintf("%s-%s.db", clientID, time.Now().Format("20060102-150405"))
+	}
+	outputPath, err := dm.resolveRestoreOutput(opt.OutputPath)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, ErrCodeBadRequest, fmt.Sprintf("Invalid outputPath %q: %v", req.OutputPath, err))
+		return
+	}
+	opt.OutputPath = outputPath
+
+	// Nunca sobrescreve um arquivo existente sem force:true
+	if _, err := os.Stat(opt.OutputPath); err == nil {
+		if !req.Force {
+			writeAPIError(w, http.StatusConflict, ErrCodeConflict, fmt.Sprintf("Output path already exists: %s (use force:true to overwrite)", opt.OutputPath))
+			return
+		}
+		if err := os.Remove(opt.OutputPath); err != nil {
+			writeAPIError(w, http.StatusInternalServerError, ErrCodeInternal, fmt.Sprintf("Failed to remove existing output path: %v", err))
+			return
+		}
+	}
+
+	streamClientRestore(dm, w, r, clientID, opt, req.Timestamp, dm.restoreClient)
+}
+
+// streamClientRestore executa o restore transmitindo o progresso e o resultado como NDJSON
+func streamClientRestore(dm *DatabaseManager, w http.ResponseWriter, r *http.Request, clientID string, opt litestream.RestoreOptions, timestamp string,
+	restoreFn func(context.Context, string, litestream.RestoreOptions) (litestream.RestoreOptions, error)) {
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+
+	stream := newRestoreStream(w)
+	opt.Logger = log.New(stream, "", 0)
+
+	target := opt.OutputPath
+	if target == "" {
+		target = "in place"
+	}
+	logEvent(MsgRestoreStarted, clientID, target)
+	opt, err := restoreFn(r.Context(), clientID, opt)
+	if err != nil {
+		log.Printf("⚠️  Restore failed for client %s: %v", clientID, err)
+		stream.send(RestoreEvent{Type: "error", Error: err.Error()})
+		return
+	}
+
+	var size int64
+	if info, err := os.Stat(opt.OutputPath); err == nil {
+		size = info.Size()
+	}
+
+	logEvent(MsgRestoreComplete, clientID, opt.OutputPath, size)
+	stream.send(RestoreEvent{
+		Type:       "result",
+		Generation: opt.Generation,
+		OutputPath: opt.OutputPath,
+		Timestamp:  timestamp,
+		Bytes:      size,
+	})
+}
 
-	// Inicializa tempo de start do servidor
-	startTime = time.Now()
+// Contadores de ciclo de vida dos clientes (expostos em /metrics)
+var (
+	registrationsTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "litestream_manager_registrations_total",
+		Help: "Number of databases successfully registered for replication.",
+	})
+	registrationFailuresTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "litestream_manager_registration_failures_total",
+		Help: "Number of database registrations that failed.",
+	})
+	unregistrationsTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "litestream_manager_unregistrations_total",
+		Help: "Number of databases removed from replication.",
+	})
+	watcherOverflowsTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "litestream_manager_watcher_overflows_total",
+		Help: "Number of file watcher queue overflows (events lost, followed by a full rescan).",
+	})
+	registrationDurationSeconds = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "litestream_manager_registration_duration_seconds",
+		Help:    "Duration of database registration attempts, including lsdb.Open() and any restore from S3.",
+		Buckets: registrationDurationBuckets,
+	})
+)
 
-	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM)
-	defer stop()
+// registrationDurationBuckets limites (segundos) do histograma de duração dos
+// registros: de bancos locais já sincronizados até restores grandes do S3
+var registrationDurationBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120}
 
-	// Parse command line flags.
-	watchDir := flag.String("watch-dir", "", "directory to watch for GUID.db files (comma-separated for multiple)")
-	bucket := flag.String("bucket", "", "s3 replica bucket")
-	port := flag.String("port", "8080", "port for the web server (default: 8080)")
-	
+// slowestRegistrationsLimit quantidade de clientes em slowestRegistrations de /api/status
+const slowestRegistrationsLimit = 10
 
-	
-	flag.Parse()
-	
-	// Set address based on port flag
-	addr := ":" + *port
+// RegistrationTiming duração do registro de um cliente em /api/status
+type RegistrationTiming struct {
+	ClientID       string `json:"clientId"`
+	RegistrationMs int64  `json:"registrationMs"`
+}
 
-	// Validate required parameters
-	if *bucket == "" {
-		flag.Usage()
-		return fmt.Errorf("required: -bucket NAME")
+// slowestRegistrations clientes registrados que mais demoraram a abrir (chamar com o lock)
+func (dm *DatabaseManager) slowestRegistrations() []RegistrationTiming {
+	timings := make([]RegistrationTiming, 0, len(dm.databases))
+	for clientID := range dm.databases {
+		config := dm.clients[clientID]
+		timings = append(timings, RegistrationTiming{ClientID: clientID, RegistrationMs: config.RegistrationDuration.Milliseconds()})
 	}
-	
-	if *watchDir == "" {
-		flag.Usage()
-		return fmt.Errorf("required: -watch-dir PATH")
+	sort.Slice(timings, func(i, j int) bool {
+		if timings[i].RegistrationMs != timings[j].RegistrationMs {
+			return timings[i].RegistrationMs > timings[j].RegistrationMs
+		}
+		return timings[i].ClientID < timings[j].ClientID
+	})
+	if len(timings) > slowestRegistrationsLimit {
+		timings = timings[:slowestRegistrationsLimit]
 	}
-
-	// Run directory watching mode
-	return runDirectoryMode(ctx, *watchDir, *bucket, addr)
+	return timings
 }
 
-// runDirectoryMode runs the new multi-database directory watching mode
-func runDirectoryMode(ctx context.Context, watchDirStr, bucket, addr string) error {
-	watchDirs := strings.Split(watchDirStr, ",")
-	
-	// Trim spaces
-	for i, dir := range watchDirs {
-		watchDirs[i] = strings.TrimSpace(dir)
-	}
+// syncDurationBuckets limites (segundos) do histograma de duração dos syncs
+var syncDurationBuckets = []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
 
-	fmt.Println("🏢 Litestream Multi-Client Manager")
-	fmt.Println("===============================================")
-	fmt.Printf("📦 S3 Bucket: %s\n", bucket)
-	fmt.Printf("👀 Watching Directories: %v\n", watchDirs)
-	fmt.Printf("🌐 Status Server: http://localhost%s\n", addr)
-	fmt.Println()
+// Estatísticas de replicação de todos os clientes (sempre em /metrics)
+var (
+	replicatedBytesTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "litestream_manager_replicated_bytes_total",
+		Help: "Estimated WAL bytes pushed to the replicas by all clients.",
+	})
+	syncDurationSeconds = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "litestream_manager_sync_duration_seconds",
+		Help:    "Duration of replica syncs run by the manager.",
+		Buckets: syncDurationBuckets,
+	})
+)
 
-	// Create and start database manager
-	dm := NewDatabaseManager(bucket, watchDirs)
-	defer dm.Stop()
+// Estatísticas de replicação por cliente (em /metrics só com -metrics-per-client)
+var (
+	clientReplicatedBytesTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "litestream_manager_client_replicated_bytes_total",
+		Help: "Estimated WAL bytes pushed to the replicas, per client.",
+	}, []string{"client_id"})
+	clientSyncDurationSeconds = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "litestream_manager_client_sync_duration_seconds",
+		Help:    "Duration of replica syncs run by the manager, per client.",
+		Buckets: syncDurationBuckets,
+	}, []string{"client_id"})
+)
 
-	if err := dm.Start(); err != nil {
-		return fmt.Errorf("failed to start database manager: %w", err)
-	}
+// ClientStats resposta de GET /api/client/{clientID}/stats
+type ClientStats struct {
+	ClientID        string               `json:"clientId"`
+	BytesReplicated int64                `json:"bytesReplicated"` // estimado pelo avanço das posições dos replicas
+	Syncs           int                  `json:"syncs"`
+	LastSyncMs      int64                `json:"lastSyncMs"`
+	AvgSyncMs       int64                `json:"avgSyncMs"`
+	MaxSyncMs       int64                `json:"maxSyncMs"`
+	SyncDurations   []SyncDurationBucket `json:"syncDurations"`
+}
 
-	// Start status web server
-	go startStatusServer(dm, addr)
+// SyncDurationBucket quantidade de syncs com duração até LE segundos (cumulativo)
+type SyncDurationBucket struct {
+	LE    string `json:"le"`
+	Count int    `json:"count"`
+}
 
-	// Wait for signal
-	<-ctx.Done()
-	log.Print("litestream manager received signal, shutting down")
-	return nil
+// clientStats contadores internos de um cliente
+type clientStats struct {
+	bytes   int64
+	syncs   int
+	total   time.Duration
+	last    time.Duration
+	max     time.Duration
+	buckets []int // contagem por syncDurationBuckets (não cumulativa) + overflow
 }
 
+// statsFor retorna (criando) as estatísticas do cliente (chamar com statsMutex)
+func (dm *DatabaseManager) statsFor(clientID string) *clientStats {
+	stats, exists := dm.stats[clientID]
+	if !exists {
+		stats = &clientStats{buckets: make([]int, len(syncDurationBuckets)+1)}
+		dm.stats[clientID] = stats
+	}
+	return stats
+}
 
+// observeSync registra a duração de um sync completo de um cliente
+func (dm *DatabaseManager) observeSync(clientID string, d time.Duration) {
+	syncDurationSeconds.Observe(d.Seconds())
+	if dm.config.MetricsPerClient {
+		clientSyncDurationSeconds.WithLabelValues(clientID).Observe(d.Seconds())
+	}
 
-// extractClientID extracts GUID from database filename for S3 organization
-// Expected format: /data/12345678-1234-5678-9abc-123456789012.db
-func extractClientID(dbPath string) string {
-	// Extract filename from path
-	base := filepath.Base(dbPath)
-	guid := strings.TrimSuffix(base, filepath.Ext(base))
-	
-	// Validate GUID format
-	if isValidGUID(guid) {
-		return guid
+	dm.statsMutex.Lock()
+	defer dm.statsMutex.Unlock()
+	stats := dm.statsFor(clientID)
+	stats.syncs++
+	stats.total += d
+	stats.last = d
+	if d > stats.max {
+		stats.max = d
 	}
-	
-	// Return empty string for invalid GUIDs - will be ignored
-	return ""
+	i := sort.SearchFloat64s(syncDurationBuckets, d.Seconds())
+	stats.buckets[i]++
 }
 
-// isValidGUID validates if string follows GUID pattern
-func isValidGUID(s string) bool {
-	// Basic GUID validation: xxxxxxxx-xxxx-xxxx-xxxx-xxxxxxxxxxxx
-	if len(s) != 36 {
-		return false
+// addReplicatedBytes soma os bytes enviados aos replicas do cliente
+func (dm *DatabaseManager) addReplicatedBytes(clientID string, n int64) {
+	if n <= 0 {
+		return
 	}
-	if s[8] != '-' || s[13] != '-' || s[18] != '-' || s[23] != '-' {
-		return false
+	replicatedBytesTotal.Add(float64(n))
+	if dm.config.MetricsPerClient {
+		clientReplicatedBytesTotal.WithLabelValues(clientID).Add(float64(n))
 	}
-	return true
+
+	dm.statsMutex.Lock()
+	defer dm.statsMutex.Unlock()
+	dm.statsFor(clientID).bytes += n
 }
 
-// NewDatabaseManager cria novo gerenciador otimizado (1:1 cliente:banco)
-func NewDatabaseManager(bucket string, watchDirs []string) *DatabaseManager {
-	ctx, cancel := context.WithCancel(context.Background())
-	
-	watcher, err := fsnotify.NewWatcher()
-	if err != nil {
-		log.Fatal("Failed to create file watcher:", err)
+// replicatedBytes estima os bytes enviados entre duas posições do replica; quando
+// o índice ou a geração mudam o tamanho dos segmentos anteriores não é conhecido
+// e apenas o offset atual é contado
+func replicatedBytes(prev, cur litestream.Pos) int64 {
+	if cur.IsZero() {
+		return 0
 	}
-
-	return &DatabaseManager{
-		databases: make(map[string]*litestream.DB),   // clientID -> DB
-		clients:   make(map[string]*ClientConfig),    // clientID -> config
-		pathIndex: make(map[string]string),           // path -> clientID
-		watcher:   watcher,
-		bucket:    bucket,
-		watchDirs: watchDirs,
-		ctx:       ctx,
-		cancel:    cancel,
+	if prev.Generation == cur.Generation && prev.Index == cur.Index {
+		return cur.Offset - prev.Offset
 	}
+	return cur.Offset
 }
 
-// Start inicia o monitoramento de diretórios
-func (dm *DatabaseManager) Start() error {
-	// Adiciona diretórios para monitoramento
-	for _, dir := range dm.watchDirs {
-		if err := dm.addWatchDir(dir); err != nil {
-			log.Printf("❌ Failed to watch directory %s: %v", dir, err)
-			continue
+// clientStatsData monta a resposta de /api/client/{clientID}/stats
+func (dm *DatabaseManager) clientStatsData(clientID string) ClientStats {
+	dm.statsMutex.Lock()
+	defer dm.statsMutex.Unlock()
+
+	data := ClientStats{ClientID: clientID, SyncDurations: []SyncDurationBucket{}}
+	stats, exists := dm.stats[clientID]
+	if !exists {
+		return data
+	}
+
+	data.BytesReplicated = stats.bytes
+	data.Syncs = stats.syncs
+	data.LastSyncMs = stats.last.Milliseconds()
+	data.MaxSyncMs = stats.max.Milliseconds()
+	if stats.syncs > 0 {
+		data.AvgSyncMs = (stats.total / time.Duration(stats.syncs)).Milliseconds()
+	}
+
+	cumulative := 0
+	for i, count := range stats.buckets {
+		cumulative += count
+		le := "+Inf"
+		if i < len(syncDurationBuckets) {
+			le = strconv.FormatFloat(syncDurationBuckets[i], 'f', -1, 64)
 		}
-		log.Printf("👀 Watching directory: %s", dir)
+		data.SyncDurations = append(data.SyncDurations, SyncDurationBucket{LE: le, Count: cumulative})
 	}
+	return data
+}
 
-	// Inicia goroutine de monitoramento
-	go dm.watchFiles()
-	
-	// Escaneia arquivos existentes
-	return dm.scanExistingDatabases()
+// dropStats remove as estatísticas e séries Prometheus de um cliente removido
+func (dm *DatabaseManager) dropStats(clientID string) {
+	clientReplicatedBytesTotal.DeleteLabelValues(clientID)
+	clientSyncDurationSeconds.DeleteLabelValues(clientID)
+
+	dm.statsMutex.Lock()
+	defer dm.statsMutex.Unlock()
+	delete(dm.stats, clientID)
 }
 
-// Stop para o gerenciador (1:1 otimizado)
-func (dm *DatabaseManager) Stop() {
-	dm.cancel()
-	dm.watcher.Close()
-	
-	dm.mutex.Lock()
-	defer dm.mutex.Unlock()
-	
-	// Iteração otimizada usando clientID como chave
-	for clientID, db := range dm.databases {
-		db.SoftClose()
-		log.Printf("❌ Stopped replication: %s", clientID)
-	}
-	
-	log.Printf("📁 Database manager stopped")
+// metricsCollector calcula no momento do scrape as métricas que dependem do
+// estado do gerenciador, para que clientes removidos não deixem séries órfãs
+type metricsCollector struct {
+	dm            *DatabaseManager
+	clientsTotal  *prometheus.Desc
+	clientsActive *prometheus.Desc
+	uptime        *prometheus.Desc
+	replicaIndex  *prometheus.Desc
+	replicaOffset *prometheus.Desc
 }
 
-// addWatchDir adiciona diretório para monitoramento
-func (dm *DatabaseManager) addWatchDir(dir string) error {
-	// Verificar se o diretório existe
-	info, err := os.Stat(dir)
-	if err != nil {
-		if os.IsNotExist(err) {
-			return fmt.Errorf("directory does not exist: %s (please create it first)", dir)
-		}
-		return fmt.Errorf("failed to access directory %s: %w", dir, err)
-	}
-	
-	// Verificar se é realmente um diretório
-	if !info.IsDir() {
-		return fmt.Errorf("path is not a directory: %s", dir)
+func newMetricsCollector(dm *DatabaseManager) *metricsCollector {
+	return &metricsCollector{
+		dm: dm,
+		clientsTotal: prometheus.NewDesc("litestream_manager_clients_total",
+			"Number of known clients.", nil, nil),
+		clientsActive: prometheus.NewDesc("litestream_manager_clients_active",
+			"Number of clients with an open Litestream database.", nil, nil),
+		uptime: prometheus.NewDesc("litestream_manager_uptime_seconds",
+			"Seconds since the manager started.", nil, nil),
+		replicaIndex: prometheus.NewDesc("litestream_manager_replica_wal_index",
+			"WAL index of the last position replicated for a client.", []string{"client_id", "replica"}, nil),
+		replicaOffset: prometheus.NewDesc("litestream_manager_replica_wal_offset",
+			"WAL offset of the last position replicated for a client.", []string{"client_id", "replica"}, nil),
 	}
-	
-	// Verificar se temos permissão de escrita (para criar arquivos de teste)
-	testFile := filepath.Join(dir, ".litestream-access-test")
-	if err := os.WriteFile(testFile, []byte("test"), 0644); err != nil {
-		return fmt.Errorf("directory is not writable: %s (error: %v)", dir, err)
-	}
-	os.Remove(testFile) // Limpar arquivo de teste
-	
-	return dm.watcher.Add(dir)
 }
 
-// watchFiles monitora mudanças nos arquivos
-func (dm *DatabaseManager) watchFiles() {
-	for {
-		select {
-		case <-dm.ctx.Done():
-			return
-		case event, ok := <-dm.watcher.Events:
-			if !ok {
-				return
-			}
-			dm.handleFileEvent(event)
-		case err, ok := <-dm.watcher.Errors:
-			if !ok {
-				return
-			}
-			log.Printf("⚠️  File watcher error: %v", err)
-		}
-	}
+func (c *metricsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.clientsTotal
+	ch <- c.clientsActive
+	ch <- c.uptime
+	ch <- c.replicaIndex
+	ch <- c.replicaOffset
 }
 
-// handleFileEvent processa eventos de arquivo
-func (dm *DatabaseManager) handleFileEvent(event fsnotify.Event) {
-	if !dm.isDatabaseFile(event.Name) {
+func (c *metricsCollector) Collect(ch chan<- prometheus.Metric) {
+	c.dm.mutex.RLock()
+	defer c.dm.mutex.RUnlock()
+
+	ch <- prometheus.MustNewConstMetric(c.clientsTotal, prometheus.GaugeValue, float64(len(c.dm.clients)))
+	ch <- prometheus.MustNewConstMetric(c.clientsActive, prometheus.GaugeValue, float64(len(c.dm.databases)))
+	ch <- prometheus.MustNewConstMetric(c.uptime, prometheus.GaugeValue, time.Since(startTime).Seconds())
+
+	// Uma série por cliente e replica: só com -metrics-per-client
+	if !c.dm.config.MetricsPerClient {
 		return
 	}
-
-	switch {
-	case event.Op&fsnotify.Create == fsnotify.Create:
-		log.Printf("📁 Database created: %s", event.Name)
-		dm.registerDatabase(event.Name)
-	case event.Op&fsnotify.Remove == fsnotify.Remove:
-		if dm.isDatabaseFile(event.Name) {
-			log.Printf("🗑️  Database removed: %s", event.Name) 
-			dm.unregisterDatabase(event.Name)
+	for clientID, lsdb := range c.dm.databases {
+		for _, replica := range lsdb.Replicas {
+			pos := replica.Pos()
+			ch <- prometheus.MustNewConstMetric(c.replicaIndex, prometheus.GaugeValue, float64(pos.Index), clientID, replica.Name())
+			ch <- prometheus.MustNewConstMetric(c.replicaOffset, prometheus.GaugeValue, float64(pos.Offset), clientID, replica.Name())
 		}
-	case event.Op&fsnotify.Write == fsnotify.Write:
-		// Arquivo modificado - já está sendo replicado
 	}
 }
 
-// isDatabaseFile verifica se é arquivo de banco
-func (dm *DatabaseManager) isDatabaseFile(filename string) bool {
-	ext := strings.ToLower(filepath.Ext(filename))
-	return ext == ".db" || ext == ".sqlite" || ext == ".sqlite3"
-}
+// certReloaderCheckInterval intervalo mínimo entre verificações dos arquivos do certificado
+const certReloaderCheckInterval = 10 * time.Second
 
-// isClientRegistered verifica se cliente já está registrado
-func (dm *DatabaseManager) isClientRegistered(clientID string) bool {
-	dm.mutex.RLock()
-	defer dm.mutex.RUnlock()
-	_, exists := dm.databases[clientID]
-	return exists
+// certReloader recarrega o certificado TLS quando os arquivos mudam no disco
+// (ex: rotação pelo cert-manager)
+type certReloader struct {
+	certFile  string
+	keyFile   string
+	mutex     sync.Mutex
+	cert      *tls.Certificate
+	modTime   time.Time // mtime mais recente entre cert e key
+	checkedAt time.Time
 }
 
-// registerDatabase registra novo cliente (1:1 otimizado)
-func (dm *DatabaseManager) registerDatabase(dbPath string) error {
-	dm.mutex.Lock()
-	defer dm.mutex.Unlock()
-
-	// Extrai GUID do filename
-	clientID := extractClientID(dbPath)
-	if clientID == "" {
-		return fmt.Errorf("invalid GUID format in filename: %s", filepath.Base(dbPath))
+// newCertReloader carrega o certificado, falhando se cert/key forem inválidos
+func newCertReloader(certFile, keyFile string) (*certReloader, error) {
+	cr := &certReloader{certFile: certFile, keyFile: keyFile}
+	modTime, err := cr.latestModTime()
+	if err != nil {
+		return nil, err
+	}
+	if err := cr.load(modTime); err != nil {
+		return nil, err
 	}
+	return cr, nil
+}
 
-	// Verifica se cliente já existe (usar clientID como chave primária)
-	if _, exists := dm.databases[clientID]; exists {
-		return fmt.Errorf("client already registered: %s", clientID)
+// latestModTime mtime mais recente entre os arquivos de cert e key
+func (cr *certReloader) latestModTime() (time.Time, error) {
+	var latest time.Time
+	for _, path := range []string{cr.certFile, cr.keyFile} {
+		info, err := os.Stat(path)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
 	}
+	return latest, nil
+}
 
-	// Verifica se path já está mapeado
-	if existingClientID, exists := dm.pathIndex[dbPath]; exists {
-		return fmt.Errorf("path already mapped to client: %s -> %s", dbPath, existingClientID)
+// load lê o par cert/key (chamar com o mutex ou antes de publicar o reloader)
+func (cr *certReloader) load(modTime time.Time) error {
+	cert, err := tls.LoadX509KeyPair(cr.certFile, cr.keyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load TLS certificate: %w", err)
 	}
-	
-	// Cria configuração otimizada
-	config := &ClientConfig{
-		ClientID:     clientID,
-		DatabasePath: dbPath,
-		CreatedAt:    time.Now(),
+	cr.cert = &cert
+	cr.modTime = modTime
+	return nil
+}
+
+// GetCertificate usado pelo tls.Config; recarrega o par se os arquivos mudaram
+func (cr *certReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	cr.mutex.Lock()
+	defer cr.mutex.Unlock()
+
+	if time.Since(cr.checkedAt) >= certReloaderCheckInterval {
+		cr.checkedAt = time.Now()
+		if modTime, err := cr.latestModTime(); err == nil && modTime.After(cr.modTime) {
+			// Mantém o certificado anterior se a nova versão ainda estiver incompleta
+			if err := cr.load(modTime); err != nil {
+				log.Printf("⚠️  Keeping previous TLS certificate: %v", err)
+			} else {
+				log.Printf("🔒 TLS certificate reloaded: %s", cr.certFile)
+			}
+		}
 	}
+	return cr.cert, nil
+}
 
-	// Cria instância Litestream
-	lsdb := litestream.NewDB(dbPath)
-	
-	// Configura S3 (path inline para performance)
-	client := lss3.NewReplicaClient()
-	client.Bucket = dm.bucket
-	client.Path = fmt.Sprintf("databases/%s", clientID)
+// templateCheckInterval intervalo mínimo entre verificações do arquivo de -template
+const templateCheckInterval = 2 * time.Second
 
-	replica := litestream.NewReplica(lsdb, "s3")
-	replica.Client = client
-	lsdb.Replicas = append(lsdb.Replicas, replica)
+// dashboardTemplate template do dashboard. Com -template, o arquivo externo é
+// relido quando muda no disco; enquanto ele não for válido, continua servindo
+// o último template bom (o embutido, se o externo nunca foi válido).
+type dashboardTemplate struct {
+	path      string
+	mutex     sync.Mutex
+	tmpl      *template.Template
+	modTime   time.Time
+	checkedAt time.Time
+}
 
-	// Inicializa
-	if err := lsdb.Open(); err != nil {
-		return fmt.Errorf("failed to open database %s: %v", dbPath, err)
+// newDashboardTemplate interpreta o template embutido e, com path, o externo
+func newDashboardTemplate(path string) (*dashboardTemplate, error) {
+	embedded, err := template.New("dashboard").Parse(templateContent)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse embedded template: %w", err)
 	}
 
-	// Registra usando clientID como chave primária
-	dm.databases[clientID] = lsdb
-	dm.clients[clientID] = config
-	dm.pathIndex[dbPath] = clientID
+	dt := &dashboardTemplate{path: path, tmpl: embedded}
+	if path != "" {
+		dt.reload()
+	}
+	return dt, nil
+}
 
-	log.Printf("✅ Client registered: %s -> s3://%s/databases/%s/", 
-		clientID, dm.bucket, clientID)
+// Get retorna o template atual, relendo o arquivo externo se ele mudou
+func (dt *dashboardTemplate) Get() *template.Template {
+	dt.mutex.Lock()
+	defer dt.mutex.Unlock()
 
-	return nil
+	if dt.path != "" && time.Since(dt.checkedAt) >= templateCheckInterval {
+		dt.reload()
+	}
+	return dt.tmpl
 }
 
-// unregisterDatabase remove cliente (1:1 otimizado) 
-func (dm *DatabaseManager) unregisterDatabase(dbPath string) error {
-	dm.mutex.Lock()
-	defer dm.mutex.Unlock()
-
-	// Lookup otimizado via pathIndex
-	clientID, exists := dm.pathIndex[dbPath]
-	if !exists {
-		return nil // Silencioso se não existe
+// reload lê o arquivo externo se o mtime mudou (chamar com o lock). O template
+// também é executado com dados vazios para detectar campos inexistentes antes
+// de servi-lo.
+func (dt *dashboardTemplate) reload() {
+	dt.checkedAt = time.Now()
+	info, err := os.Stat(dt.path)
+	if err != nil {
+		if !dt.modTime.IsZero() {
+			log.Printf("⚠️  Keeping previous dashboard template: %v", err)
+			dt.modTime = time.Time{}
+		}
+		return
+	}
+	if info.ModTime().Equal(dt.modTime) {
+		return
 	}
+	dt.modTime = info.ModTime()
 
-	lsdb, dbExists := dm.databases[clientID] // O(1) lookup
-	if dbExists {
-		// Para replicação imediatamente 
-		lsdb.Close()
+	data, err := os.ReadFile(dt.path)
+	if err != nil {
+		log.Printf("⚠️  Keeping previous dashboard template: %v", err)
+		return
 	}
-	
-	// Remove de todos os mapas
-	delete(dm.databases, clientID)
-	delete(dm.clients, clientID)
-	delete(dm.pathIndex, dbPath)
+	tmpl, err := template.New("dashboard").Parse(string(data))
+	if err == nil {
+		err = tmpl.Execute(io.Discard, DashboardData{})
+	}
+	if err != nil {
+		log.Printf("❌ Invalid dashboard template %s, keeping previous one: %v", dt.path, err)
+		return
+	}
+	dt.tmpl = tmpl
+	log.Printf("🎨 Dashboard template loaded: %s", dt.path)
+}
 
-	log.Printf("❌ Client unregistered: %s", clientID)
+// requireToken exige "Authorization: Bearer <token>" nas rotas /api/*
+// (e no dashboard com protectDashboard). Sem token configurado não altera nada.
+func requireToken(next http.Handler, token string, protectDashboard bool) http.Handler {
+	if token == "" {
+		return next
+	}
+	expected := []byte("Bearer " + token)
 
-	return nil
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		protected := strings.HasPrefix(r.URL.Path, "/api/") || (protectDashboard && r.URL.Path == "/")
+		if protected && subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), expected) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="litestream-manager"`)
+			if strings.HasPrefix(r.URL.Path, "/api/") {
+				writeAPIError(w, http.StatusUnauthorized, ErrCodeUnauthorized, "Missing or invalid bearer token")
+			} else {
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			}
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
 }
 
-// scanExistingDatabases escaneia bancos existentes
-func (dm *DatabaseManager) scanExistingDatabases() error {
-	for _, watchDir := range dm.watchDirs {
-		err := filepath.Walk(watchDir, func(path string, info os.FileInfo, err error) error {
-			if err != nil {
-				return err
+// recoverPanics converte o panic de um handler em 500, com stack no log, para que
+// uma requisição com problema não derrube o gerenciador nem a replicação
+func recoverPanics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			rec := recover()
+			if rec == nil {
+				return
 			}
-			
-			if !info.IsDir() && dm.isDatabaseFile(path) {
-				clientID := extractClientID(path)
-				if clientID != "" && !dm.isClientRegistered(clientID) {
-					if err := dm.registerDatabase(path); err != nil {
-						log.Printf("⚠️  Failed to register existing database %s: %v", path, err)
-					}
-				}
+			if rec == http.ErrAbortHandler {
+				panic(rec) // abort intencional: o net/http encerra a conexão sem log
 			}
-			return nil
-		})
-		
-		if err != nil {
-			log.Printf("⚠️  Failed to scan directory %s: %v", watchDir, err)
-		}
-	}
-	
-	dm.mutex.RLock()
-	clientCount := len(dm.databases)
-	dm.mutex.RUnlock()
-	
-	log.Printf("🎯 Monitoring %d clients across %d directories", clientCount, len(dm.watchDirs))
-	return nil
+			log.Printf("💥 Panic serving %s %s: %v\n%s", r.Method, r.URL.Path, rec, debug.Stack())
+			if strings.HasPrefix(r.URL.Path, "/api/") {
+				writeAPIError(w, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
+			} else {
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+			}
+		}()
+		next.ServeHTTP(w, r)
+	})
 }
 
+// clientStatus estado de um cliente como aparece em /api/status (chamar com o lock)
+func (dm *DatabaseManager) clientStatus(clientID string) map[string]interface{} {
+	config := dm.clients[clientID]
+	status := "active"
+	replicas := []ReplicaData{}
+	if lsdb, exists := dm.databases[clientID]; exists {
+		replicas = replicaData(lsdb)
+	} else if dm.paused[clientID] {
+		status = "paused"
+	} else if dm.config.DryRun {
+		status = "dry-run"
+	} else {
+		status = "inactive"
+	}
 
+	client := map[string]interface{}{
+		"clientId":          clientID,
+		"databasePath":      config.DatabasePath,
+		"s3Path":            config.S3Path,
+		"status":            status,
+		"createdAt":         formatTimestamp(config.CreatedAt),
+		"replicas":          replicas,
+		"collidesWith":      config.CollidesWith,
+		"firstReplicatedAt": formatTime(config.FirstReplicatedAt),
+		"s3Health":          dm.s3Health(clientID),
+		"registrationMs":    config.RegistrationDuration.Milliseconds(),
+		"displayName":       config.Metadata.displayName(),
+		"tags":              config.Metadata.tags(),
+		"generationCount":   nil, // null até o poller listar o replica principal
+		"totalBackupBytes":  nil,
+	}
+	if cached := dm.cachedStatus(clientID); cached != nil && cached.backup != nil {
+		client["generationCount"] = cached.backup.generationCount
+		client["totalBackupBytes"] = cached.backup.totalBackupBytes
+	}
+	return client
+}
 
-func replicate(ctx context.Context, dsn, bucket, dbName string) (*litestream.DB, error) {
-	// Create Litestream DB reference for managing replication.
-	lsdb := litestream.NewDB(dsn)
+// clientSummary resposta de GET /api/client/{clientID}: o estado de
+// /api/status mais a posição atual do banco (chamar com o lock)
+func (dm *DatabaseManager) clientSummary(clientID string) map[string]interface{} {
+	summary := dm.clientStatus(clientID)
+	summary["generation"] = ""
+	summary["position"] = ""
+	summary["asOf"] = formatTimestamp(time.Now())
+	if cached := dm.cachedStatus(clientID); cached != nil {
+		summary["generation"] = cached.generation
+		summary["position"] = cached.position
+		summary["asOf"] = formatTimestamp(cached.asOf)
+	} else if lsdb, exists := dm.databases[clientID]; exists {
+		if pos, err := lsdb.Pos(); err == nil && !pos.IsZero() {
+			summary["generation"] = pos.Generation
+			summary["position"] = fmt.Sprintf("%d/%d", pos.Index, pos.Offset)
+		}
+	}
+	summary["lastSync"] = dm.lastSync(clientID)
+	return summary
+}
 
-	// Build S3 replica and attach to database.
-	client := lss3.NewReplicaClient()
-	client.Bucket = bucket
-	client.Path = fmt.Sprintf("databases/%s", dbName) // Path: databases/{guid}/
+// clientPage filtro e paginação de /api/status (?status=, ?limit=, ?offset=)
+type clientPage struct {
+	Status string
+	Limit  int // 0 = todos
+	Offset int
+}
 
-	replica := litestream.NewReplica(lsdb, "s3")
-	replica.Client = client
+// clientStatuses valores aceitos em ?status=
+var clientStatuses = map[string]bool{"active": true, "inactive": true, "paused": true, "dry-run": true}
 
-	lsdb.Replicas = append(lsdb.Replicas, replica)
+// statusFailed arquivos não replicados (colisão, recusa, falha...); como não
+// são clientes, o filtro existe só no dashboard, não em /api/status
+const statusFailed = "failed"
 
-	if err := restore(ctx, replica); err != nil {
-		return nil, err
+// parseClientPage lê os parâmetros de paginação, recusando valores inválidos
+func parseClientPage(query url.Values) (clientPage, error) {
+	var page clientPage
+	page.Status = query.Get("status")
+	if page.Status != "" && !clientStatuses[page.Status] {
+		return page, fmt.Errorf("invalid status %q: must be active, inactive, paused or dry-run", page.Status)
 	}
 
-	// Initialize database.
-	if err := lsdb.Open(); err != nil {
-		return nil, err
+	for name, value := range map[string]*int{"limit": &page.Limit, "offset": &page.Offset} {
+		raw := query.Get(name)
+		if raw == "" {
+			continue
+		}
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 0 {
+			return page, fmt.Errorf("invalid %s %q: must be a non-negative integer", name, raw)
+		}
+		*value = n
 	}
-
-	return lsdb, nil
+	return page, nil
 }
 
-func restore(ctx context.Context, replica *litestream.Replica) (err error) {
-	// Skip restore if local database already exists.
-	if _, err := os.Stat(replica.DB().Path()); err == nil {
-		fmt.Println("local database already exists, skipping restore")
-		return nil
-	} else if !os.IsNotExist(err) {
-		return err
+// apply retorna a página pedida da lista
+func (p clientPage) apply(clients []map[string]interface{}) []map[string]interface{} {
+	if p.Offset >= len(clients) {
+		return clients[:0]
 	}
-
-	// Configure restore to write out to DSN path.
-	opt := litestream.NewRestoreOptions()
-	opt.OutputPath = replica.DB().Path()
-	opt.Logger = log.New(os.Stderr, "", log.LstdFlags|log.Lmicroseconds)
-
-	// Determine the latest generation to restore from.
-	if opt.Generation, _, err = replica.CalcRestoreTarget(ctx, opt); err != nil {
-		return err
+	clients = clients[p.Offset:]
+	if p.Limit > 0 && p.Limit < len(clients) {
+		clients = clients[:p.Limit]
 	}
+	return clients
+}
 
-	// Only restore if there is a generation available on the replica.
-	// Otherwise we'll let the application create a new database.
-	if opt.Generation == "" {
-		fmt.Println("no generation found, creating new database")
-		return nil
+// allowCORS adiciona os cabeçalhos CORS nas rotas /api/* para as origens de
+// -cors-origin e responde aos preflights OPTIONS (antes da autenticação, pois o
+// navegador não envia o token no preflight)
+func allowCORS(next http.Handler, origins []string) http.Handler {
+	if len(origins) == 0 {
+		return next
 	}
-
-	fmt.Printf("restoring replica for generation %s\n", opt.Generation)
-	if err := replica.Restore(ctx, opt); err != nil {
-		return err
+	allowed := make(map[string]bool, len(origins))
+	for _, origin := range origins {
+		allowed[strings.TrimSuffix(origin, "/")] = true
 	}
-	fmt.Println("restore complete")
-	return nil
-}
 
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if !strings.HasPrefix(r.URL.Path, "/api/") || origin == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
 
+		w.Header().Add("Vary", "Origin")
+		if allowed["*"] || allowed[origin] {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, DELETE, OPTIONS")
+			w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type")
+			w.Header().Set("Access-Control-Max-Age", "600")
+		}
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
 
-// startStatusServer inicia servidor de status usando template HTML
-func startStatusServer(dm *DatabaseManager, addr string) {
-	// Parse embedded template
-	tmpl, err := template.New("dashboard").Parse(templateContent)
+// startStatusServer inicia servidor de status usando template HTML.
+// Retorna o servidor para que o chamador possa encerrá-lo com Shutdown.
+func startStatusServer(dm *DatabaseManager, ln net.Listener) (*http.Server, error) {
+	// Template embutido, ou o de -template recarregado quando muda
+	tmpl, err := newDashboardTemplate(dm.config.DashboardTemplate)
 	if err != nil {
-		log.Fatal("Failed to parse embedded template:", err)
+		return nil, err
 	}
 
-	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+	// Mux próprio: evita conflito de rotas no http.DefaultServeMux global
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		// ?status= mostra só os clientes nesse estado (mesmos valores de /api/status)
+		statusFilter := r.URL.Query().Get("status")
+		if statusFilter != "" && statusFilter != statusFailed && !clientStatuses[statusFilter] {
+			http.Error(w, fmt.Sprintf("invalid status %q: must be active, inactive, paused, dry-run or failed", statusFilter), http.StatusBadRequest)
+			return
+		}
+
 		dm.mutex.RLock()
 		defer dm.mutex.RUnlock()
 		
@@ -917,37 +6864,178 @@ func startStatusServer(dm *DatabaseManager, addr string) {
 		var clients []ClientData
 		for _, clientID := range clientIDs {
 			config := dm.clients[clientID]
+			status := "active"
 			statusClass := "status-active"
 			statusText := "ACTIVE"
-			if _, exists := dm.databases[clientID]; !exists {
+			var replicas []ReplicaData
+			generation, position := "unknown", "unknown"
+			if lsdb, exists := dm.databases[clientID]; exists {
+				replicas = replicaData(lsdb)
+				if cached := dm.cachedStatus(clientID); cached != nil {
+					if cached.generation != "" {
+						generation, position = cached.generation, cached.position
+					}
+				} else if pos, err := lsdb.Pos(); err == nil && !pos.IsZero() {
+					// Erro no Pos() não deve derrubar a página inteira
+					generation = pos.Generation
+					position = fmt.Sprintf("%d/%d", pos.Index, pos.Offset)
+				}
+			} else if dm.paused[clientID] {
+				status = "paused"
+				statusClass = "status-paused"
+				statusText = "PAUSED"
+			} else if dm.config.DryRun {
+				status = "dry-run"
+				statusClass = "status-paused"
+				statusText = "DRY RUN"
+			} else {
+				status = "inactive"
 				statusClass = "status-inactive"
 				statusText = "INACTIVE"
 			}
 			
+			var warning string
+			if config.CollidesWith != "" {
+				warning = fmt.Sprintf("Client ID also used by %s (replicated with directory suffix)", config.CollidesWith)
+			}
+			
 			clients = append(clients, ClientData{
-				ClientID:     clientID,
-				DatabasePath: config.DatabasePath,
-				StatusClass:  statusClass,
-				StatusText:   statusText,
-				CreatedAt:    config.CreatedAt.Format("2006-01-02 15:04:05"),
+				ClientID:          clientID,
+				DisplayName:       config.Metadata.displayName(),
+				Tags:              config.Metadata.tags(),
+				DatabasePath:      config.DatabasePath,
+				Status:            status,
+				StatusClass:       statusClass,
+				StatusText:        statusText,
+				CreatedAt:         formatTimestamp(config.CreatedAt),
+				Warning:           warning,
+				Generation:        generation,
+				Position:          position,
+				LastSync:          dm.lastSync(clientID),
+				FirstReplicatedAt: formatTime(config.FirstReplicatedAt),
+				Registered:        true,
+				Replicas:          replicas,
+			})
+		}
+		
+		// Arquivos não replicados por colisão de clientID
+		for _, collision := range dm.idCollisions() {
+			clients = append(clients, ClientData{
+				ClientID:     collision.ClientID,
+				DatabasePath: collision.DatabasePath,
+				Status:       statusFailed,
+				StatusClass:  "status-failed",
+				StatusText:   "COLLISION",
+				CreatedAt:    formatTimestamp(collision.DetectedAt),
+				Warning:      fmt.Sprintf("Not replicated: client ID already used by %s", collision.ExistingPath),
+			})
+		}
+		
+		// Arquivos que não são bancos SQLite
+		for _, problem := range dm.registrationProblems() {
+			if problem.Kind != ProblemNotSQLite {
+				continue // erros de abertura aparecem como FAILED ao esgotar as tentativas
+			}
+			clients = append(clients, ClientData{
+				ClientID:     problem.ClientID,
+				DatabasePath: problem.DatabasePath,
+				Status:       statusFailed,
+				StatusClass:  "status-failed",
+				StatusText:   "NOT SQLITE",
+				CreatedAt:    formatTimestamp(problem.DetectedAt),
+				Warning:      "Not replicated: the file is not a SQLite database",
 			})
 		}
 		
+		// Arquivos recusados por -max-clients
+		for _, rejection := range dm.rejectedRegistrations() {
+			clients = append(clients, ClientData{
+				ClientID:     rejection.ClientID,
+				DatabasePath: rejection.DatabasePath,
+				Status:       statusFailed,
+				StatusClass:  "status-failed",
+				StatusText:   "REJECTED",
+				CreatedAt:    formatTimestamp(rejection.RejectedAt),
+				Warning:      fmt.Sprintf("Not replicated: -max-clients (%d) reached", dm.config.MaxClients),
+			})
+		}
+		
+		// Arquivos maiores que -max-db-size
+		for _, file := range dm.skippedFiles() {
+			clients = append(clients, ClientData{
+				ClientID:     file.ClientID,
+				DatabasePath: file.DatabasePath,
+				Status:       statusFailed,
+				StatusClass:  "status-failed",
+				StatusText:   "TOO LARGE",
+				CreatedAt:    formatTimestamp(file.DetectedAt),
+				Warning:      "Not replicated: " + file.Reason,
+			})
+		}
+		
+		// Bancos que esgotaram as tentativas de registro
+		for _, failure := range dm.failedRegistrations() {
+			clients = append(clients, ClientData{
+				ClientID:     failure.ClientID,
+				DatabasePath: failure.DatabasePath,
+				Status:       statusFailed,
+				StatusClass:  "status-failed",
+				StatusText:   "FAILED",
+				CreatedAt:    formatTimestamp(failure.FailedAt),
+			})
+		}
+		
+		// O filtro vale só para a lista: os totais do cabeçalho continuam completos.
+		// Arquivos não replicados (colisão, FAILED...) têm status failed.
+		if statusFilter != "" {
+			filtered := clients[:0]
+			for _, client := range clients {
+				if client.Status == statusFilter {
+					filtered = append(filtered, client)
+				}
+			}
+			clients = filtered
+		}
+		
+		// O navegador não envia o token Bearer no fetch: com -auth-token a
+		// atualização automática falharia, então a página é estática
+		refreshMs := dm.config.DashboardRefresh.Milliseconds()
+		if dm.config.AuthToken != "" {
+			refreshMs = 0
+		}
+		
 		data := DashboardData{
 			Bucket:        dm.bucket,
+			Buckets:       dm.buckets,
 			WatchDirCount: len(dm.watchDirs),
+			DegradedDirs:  dm.degradedDirList(),
 			ClientCount:   len(dm.clients),
 			Uptime:        formatUptime(),
+			DryRun:        dm.config.DryRun,
+			RefreshMs:     refreshMs,
+			LocalDiskUsed: formatBytes(dm.localDiskBytes()),
+			StatusFilter:  statusFilter,
 			Clients:       clients,
 		}
 		
 		// Renderizar template
-		if err := tmpl.Execute(w, data); err != nil {
+		if err := tmpl.Get().Execute(w, data); err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 		}
 	})
 	
-	http.HandleFunc("/api/status", func(w http.ResponseWriter, r *http.Request) {
+	mux.HandleFunc("/api/version", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(versionInfo())
+	})
+	
+	mux.HandleFunc("/api/status", func(w http.ResponseWriter, r *http.Request) {
+		page, err := parseClientPage(r.URL.Query())
+		if err != nil {
+			writeAPIError(w, http.StatusBadRequest, ErrCodeBadRequest, err.Error())
+			return
+		}
+
 		dm.mutex.RLock()
 		defer dm.mutex.RUnlock()
 		
@@ -964,60 +7052,323 @@ func startStatusServer(dm *DatabaseManager, addr string) {
 		
 		// Iteração otimizada usando clientID ordenado
 		for _, clientID := range clientIDs {
-			config := dm.clients[clientID]
-			status := "active"
-			if _, exists := dm.databases[clientID]; !exists {
-				status = "inactive"
+			client := dm.clientStatus(clientID)
+			if page.Status != "" && client["status"] != page.Status {
+				continue
 			}
-			
-			clients = append(clients, map[string]interface{}{
-				"clientId":     clientID,
-				"databasePath": config.DatabasePath,
-				"s3Path":       fmt.Sprintf("databases/%s", clientID), // inline para performance
-				"status":       status,
-				"createdAt":    config.CreatedAt,
-			})
+			clients = append(clients, client)
+		}
+		
+		// Paginação sobre a lista filtrada (ordem por clientID, estável entre páginas)
+		total := len(clients)
+		clients = page.apply(clients)
+		
+		waitingDirs := make([]string, 0, len(dm.waitingDirs))
+		for dir := range dm.waitingDirs {
+			waitingDirs = append(waitingDirs, dir)
 		}
+		sort.Strings(waitingDirs)
 		
 		response := map[string]interface{}{
 			"bucket":          dm.bucket,
+			"buckets":         dm.buckets,
+			"bucketMap":       dm.config.BucketMap,
 			"watchDirs":       dm.watchDirs,
+			"waitingDirs":     waitingDirs,
+			"degradedDirs":    dm.degradedDirList(),
 			"totalClients":    len(dm.clients),    // otimizado
 			"activeClients":   len(dm.databases),  // já usa clientID
+			"pausedClients":   len(dm.paused),
 			"uptime":          formatUptime(),
+			"dryRun":          dm.config.DryRun,
+			"asOf":            formatTimestamp(time.Now()),
+			"localDiskBytes":  dm.localDiskBytes(),
 			"clients":         clients,
+			"total":           total,
+			"limit":           page.Limit,
+			"offset":          page.Offset,
+			"failedRegistrations": dm.failedRegistrations(),
+			"idCollisions":        dm.idCollisions(),
+			"rejected":            dm.rejectedRegistrations(),
+			"skipped":             dm.skippedFiles(),
+			"problems":            dm.registrationProblems(),
+			"slowestRegistrations": dm.slowestRegistrations(),
 		}
 		
 		if err := json.NewEncoder(w).Encode(response); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			log.Printf("⚠️  Failed to encode status response: %v", err)
 		}
 	})
 	
-	// Endpoint para obter gerações e snapshots de um cliente específico
-	http.HandleFunc("/api/client/", func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != "GET" {
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	// Métricas Prometheus (inclui as métricas internas do Litestream)
+	mux.Handle("/metrics", promhttp.Handler())
+	
+	// Eventos dos clientes em tempo real (Server-Sent Events)
+	mux.HandleFunc("/api/events", func(w http.ResponseWriter, r *http.Request) {
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			writeAPIError(w, http.StatusInternalServerError, ErrCodeInternal, "Streaming not supported")
 			return
 		}
 		
-		// Extrair clientID da URL: /api/client/{clientID}/generations
+		events := dm.events.Subscribe()
+		defer dm.events.Unsubscribe(events)
+		
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		fmt.Fprint(w, ": connected\n\n")
+		flusher.Flush()
+		
+		// Comentário periódico mantém a conexão aberta em proxies
+		keepalive := time.NewTicker(30 * time.Second)
+		defer keepalive.Stop()
+		
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case <-keepalive.C:
+				fmt.Fprint(w, ": keepalive\n\n")
+			case event := <-events:
+				data, err := json.Marshal(event)
+				if err != nil {
+					continue
+				}
+				fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Event, data)
+			}
+			flusher.Flush()
+		}
+	})
+	
+	// Health check para probes (503 se algum cliente estiver atrasado)
+	mux.HandleFunc("/api/health", func(w http.ResponseWriter, r *http.Request) {
+		health := dm.getHealth()
+		
+		w.Header().Set("Content-Type", "application/json")
+		if health.Status != "ok" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		
+		if err := json.NewEncoder(w).Encode(health); err != nil {
+			log.Printf("⚠️  Failed to encode health response: %v", err)
+		}
+	})
+	
+	// Endpoint para obter gerações e snapshots de um cliente específico
+	mux.HandleFunc("/api/client/", func(w http.ResponseWriter, r *http.Request) {
+		// Extrair clientID da URL: /api/client/{clientID}/{endpoint}
 		path := strings.TrimPrefix(r.URL.Path, "/api/client/")
 		parts := strings.Split(path, "/")
 		
-		if len(parts) < 2 || (parts[1] != "generations" && parts[1] != "restore-options") {
-			http.Error(w, "Invalid path. Use /api/client/{clientID}/generations or /api/client/{clientID}/restore-options", http.StatusBadRequest)
+		// /api/client/{clientID}: resumo do cliente
+		if len(parts) == 1 || (len(parts) == 2 && parts[1] == "") {
+			parts = []string{parts[0], "summary"}
+		}
+		
+		// /api/client/{clientID}/generations/{generation}/snapshots
+		var generation string
+		if len(parts) == 4 && parts[1] == "generations" && parts[3] == "snapshots" && parts[2] != "" {
+			generation = parts[2]
+			parts = []string{parts[0], "snapshots"}
+		}
+		
+		// Método aceito por cada endpoint
+		methods := map[string]string{
+			"generations":     http.MethodGet,
+			"s3-generations":  http.MethodGet,
+			"restore-options": http.MethodGet,
+			"restore":         http.MethodPost,
+			"snapshots":       http.MethodGet,
+			"pause":           http.MethodPost,
+			"resume":          http.MethodPost,
+			"sync":            http.MethodPost,
+			"verify":          http.MethodPost,
+			"stats":           http.MethodGet,
+			"disk-usage":      http.MethodGet,
+			"summary":         http.MethodGet,
+			"remove":          http.MethodDelete,
+		}
+		
+		if len(parts) < 2 || parts[0] == "" || methods[parts[1]] == "" || (parts[1] == "snapshots" && generation == "") {
+			writeAPIError(w, http.StatusNotFound, ErrCodeNotFound, "Invalid path. Use /api/client/{clientID}, /api/client/{clientID}/generations, /api/client/{clientID}/generations/{generation}/snapshots, /api/client/{clientID}/s3-generations, /api/client/{clientID}/restore-options, /api/client/{clientID}/restore, /api/client/{clientID}/pause, /api/client/{clientID}/resume, /api/client/{clientID}/sync, /api/client/{clientID}/verify, /api/client/{clientID}/stats or /api/client/{clientID}/disk-usage")
 			return
 		}
 		
 		clientID := parts[0]
 		endpoint := parts[1]
 		
+		// DELETE /api/client/{clientID}: para de gerenciar o cliente
+		if endpoint == "summary" && r.Method == http.MethodDelete {
+			endpoint = "remove"
+		}
+		
+		if r.Method != methods[endpoint] {
+			w.Header().Set("Allow", methods[endpoint])
+			writeAPIError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, fmt.Sprintf("Method %s not allowed (use %s)", r.Method, methods[endpoint]))
+			return
+		}
+		
 		dm.mutex.RLock()
 		_, exists := dm.clients[clientID]
 		dm.mutex.RUnlock()
 		
 		if !exists {
-			http.Error(w, "Client not found", http.StatusNotFound)
+			writeAPIError(w, http.StatusNotFound, ErrCodeClientNotFound, fmt.Sprintf("Client not found: %s", clientID))
+			return
+		}
+		
+		if (endpoint == "restore" || endpoint == "verify") && dm.config.NoRestore {
+			writeAPIError(w, http.StatusForbidden, ErrCodeForbidden, errRestoreDisabled.Error())
+			return
+		}
+		
+		// Restore e verify baixam o backup inteiro: limita quantos rodam ao mesmo tempo
+		if endpoint == "restore" || endpoint == "verify" {
+			select {
+			case dm.restoreSlots <- struct{}{}:
+				defer func() { <-dm.restoreSlots }()
+			default:
+				w.Header().Set("Retry-After", strconv.Itoa(int(restoreRetryAfter.Seconds())))
+				writeAPIError(w, http.StatusTooManyRequests, ErrCodeTooManyRequests, fmt.Sprintf("Too many restores in progress (limit %d), try again later", dm.config.MaxConcurrentRestores))
+				return
+			}
+		}
+		
+		if endpoint == "restore" {
+			handleClientRestore(dm, w, r, clientID)
+			return
+		}
+		
+		if endpoint == "summary" {
+			dm.mutex.RLock()
+			var summary map[string]interface{}
+			if _, exists := dm.clients[clientID]; exists { // pode ter sido removido após a checagem
+				summary = dm.clientSummary(clientID)
+			}
+			dm.mutex.RUnlock()
+			
+			if summary == nil {
+				writeAPIError(w, http.StatusNotFound, ErrCodeClientNotFound, fmt.Sprintf("Client not found: %s", clientID))
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(summary)
+			return
+		}
+		
+		if endpoint == "disk-usage" {
+			dm.mutex.RLock()
+			var dbPath string
+			if config, ok := dm.clients[clientID]; ok {
+				dbPath = config.DatabasePath
+			}
+			dm.mutex.RUnlock()
+			
+			usage, err := localDiskUsage(dbPath)
+			if err != nil {
+				writeAPIError(w, http.StatusInternalServerError, ErrCodeInternal, err.Error())
+				return
+			}
+			usage.ClientID = clientID
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(usage)
+			return
+		}
+		
+		if endpoint == "stats" {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(dm.clientStatsData(clientID))
+			return
+		}
+		
+		if endpoint == "verify" {
+			// Restaura em arquivo temporário e valida (?table=users conta as linhas)
+			result, err := dm.verifyClient(r.Context(), clientID, r.URL.Query().Get("table"))
+			if err != nil {
+				log.Printf("⚠️  Verification failed for client %s: %v", clientID, err)
+				writeAPIError(w, http.StatusUnprocessableEntity, ErrCodeUnprocessable, err.Error())
+				return
+			}
+			
+			if result.OK {
+				logEvent(MsgBackupVerified, clientID, result.Generation)
+			} else {
+				logEvent(MsgBackupVerifyFailed, clientID, result.IntegrityCheck)
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(result)
+			return
+		}
+		
+		if endpoint == "sync" {
+			result, err := dm.syncClient(r.Context(), clientID)
+			if errors.Is(err, errClientState) {
+				writeAPIError(w, http.StatusConflict, ErrCodeConflict, err.Error())
+				return
+			} else if err != nil {
+				log.Printf("⚠️  Manual sync failed for client %s: %v", clientID, err)
+				writeAPIError(w, http.StatusBadGateway, ErrCodeUpstream, err.Error())
+				return
+			}
+			
+			logEvent(MsgManualSync, clientID, result.ElapsedMs)
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(result)
+			return
+		}
+		
+		if endpoint == "remove" {
+			purge := false
+			if value := r.URL.Query().Get("purge-s3"); value != "" {
+				var err error
+				if purge, err = strconv.ParseBool(value); err != nil {
+					writeAPIError(w, http.StatusBadRequest, ErrCodeBadRequest, fmt.Sprintf("Invalid purge-s3 %q: must be true or false", value))
+					return
+				}
+			}
+			if purge && dm.config.DryRun {
+				writeAPIError(w, http.StatusConflict, ErrCodeConflict, "purge-s3 is not available with -dry-run")
+				return
+			}
+			
+			removed, err := dm.removeClient(r.Context(), clientID, purge)
+			if errors.Is(err, errClientState) {
+				writeAPIError(w, http.StatusConflict, ErrCodeConflict, err.Error())
+				return
+			} else if err != nil {
+				log.Printf("⚠️  Failed to remove client %s: %v", clientID, err)
+				writeAPIError(w, http.StatusInternalServerError, ErrCodeInternal, err.Error())
+				return
+			}
+			
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(removed)
+			return
+		}
+		
+		if endpoint == "pause" || endpoint == "resume" {
+			var err error
+			status := "paused"
+			if endpoint == "pause" {
+				err = dm.pauseClient(clientID)
+			} else {
+				err = dm.resumeClient(clientID)
+				status = "active"
+			}
+			if errors.Is(err, errClientState) {
+				writeAPIError(w, http.StatusConflict, ErrCodeConflict, err.Error())
+				return
+			} else if err != nil {
+				log.Printf("⚠️  Failed to %s client %s: %v", endpoint, clientID, err)
+				writeAPIError(w, http.StatusInternalServerError, ErrCodeInternal, err.Error())
+				return
+			}
+			
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(map[string]string{
+				"clientId": clientID,
+				"status":   status,
+			})
 			return
 		}
 		
@@ -1025,48 +7376,105 @@ func startStatusServer(dm *DatabaseManager, addr string) {
 		
 		if endpoint == "restore-options" {
 			// Endpoint para listar todas as opções de restore
-			restoreData, err := dm.getClientRestoreOptions(clientID)
+			restoreData, err := dm.getClientRestoreOptions(r.Context(), clientID)
 			if err != nil {
 				log.Printf("⚠️  Failed to get restore options for client %s: %v", clientID, err)
-				http.Error(w, "Internal server error", http.StatusInternalServerError)
+				if errors.Is(err, errClientState) {
+					writeAPIError(w, http.StatusConflict, ErrCodeConflict, err.Error())
+				} else {
+					writeAPIError(w, http.StatusInternalServerError, ErrCodeInternal, err.Error())
+				}
 				return
 			}
 			
 			if err := json.NewEncoder(w).Encode(restoreData); err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
+				log.Printf("⚠️  Failed to encode response: %v", err)
 			}
 			return
 		}
 		
-		// Endpoint original para generations
-		// Obter gerações
-		generations, err := dm.getClientGenerations(clientID)
-		if err != nil {
-			log.Printf("⚠️  Failed to get generations for client %s: %v", clientID, err)
-			// Retorna array vazio em caso de erro para não quebrar a UI
-			generations = []GenerationData{}
+		if endpoint == "snapshots" {
+			// Snapshots e segmentos de WAL reais da geração no replica (?replica=s3-2)
+			data, err := dm.getClientS3Snapshots(r.Context(), clientID, generation, r.URL.Query().Get("replica"))
+			if errors.Is(err, errReplicaNotFound) {
+				writeAPIError(w, http.StatusNotFound, ErrCodeNotFound, err.Error())
+				return
+			} else if err != nil {
+				log.Printf("⚠️  Failed to list S3 snapshots for client %s generation %s: %v", clientID, generation, err)
+				writeAPIError(w, http.StatusBadGateway, ErrCodeUpstream, err.Error())
+				return
+			}
+			
+			if err := json.NewEncoder(w).Encode(data); err != nil {
+				log.Printf("⚠️  Failed to encode response: %v", err)
+			}
+			return
 		}
 		
-		// Obter snapshots para cada geração
-		for i := range generations {
-			snapshots, err := dm.getClientSnapshots(clientID, generations[i].ID)
+		if endpoint == "s3-generations" {
+			// Gerações lidas diretamente dos replicas remotos
+			generations, err := dm.getClientS3Generations(r.Context(), clientID)
 			if err != nil {
-				log.Printf("⚠️  Failed to get snapshots for client %s generation %s: %v", 
-					clientID, generations[i].ID, err)
-				snapshots = []SnapshotData{}
+				log.Printf("⚠️  Failed to list S3 generations for client %s: %v", clientID, err)
+				writeAPIError(w, http.StatusBadGateway, ErrCodeUpstream, err.Error())
+				return
+			}
+			
+			response := map[string]interface{}{
+				"clientId":    clientID,
+				"generations": generations,
+			}
+			
+			if err := json.NewEncoder(w).Encode(response); err != nil {
+				log.Printf("⚠️  Failed to encode response: %v", err)
+			}
+			return
+		}
+		
+		// Endpoint original para generations (do cache do poller de status)
+		var generations []GenerationData
+		asOf := time.Now()
+		if cached := dm.cachedStatus(clientID); cached != nil {
+			generations, asOf = cached.generations, cached.asOf
+		} else {
+			var err error
+			if generations, err = dm.clientGenerations(clientID); errors.Is(err, errClientState) {
+				writeAPIError(w, http.StatusConflict, ErrCodeConflict, err.Error())
+				return
+			} else if err != nil {
+				log.Printf("⚠️  Failed to get generations for client %s: %v", clientID, err)
+				writeAPIError(w, http.StatusInternalServerError, ErrCodeInternal, err.Error())
+				return
 			}
-			generations[i].Snapshots = snapshots
 		}
 		
 		response := map[string]interface{}{
 			"clientId":    clientID,
 			"generations": generations,
+			"asOf":        formatTimestamp(asOf),
 		}
 		
 		if err := json.NewEncoder(w).Encode(response); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			log.Printf("⚠️  Failed to encode response: %v", err)
 		}
 	})
 	
-	log.Fatal(http.ListenAndServe(addr, nil))
+	server := &http.Server{
+		Handler: recoverPanics(allowCORS(requireToken(mux, dm.config.AuthToken, dm.config.ProtectDash), dm.config.CORSOrigins)),
+		// Requisições derivam de dm.ctx: restores, syncs e verificações são
+		// cancelados no encerramento em vez de segurar o Shutdown
+		BaseContext: func(net.Listener) context.Context { return dm.ctx },
+	}
+	serve := server.Serve
+	if dm.config.TLS != nil {
+		server.TLSConfig = &tls.Config{GetCertificate: dm.config.TLS.GetCertificate}
+		serve = func(ln net.Listener) error { return server.ServeTLS(ln, "", "") }
+		log.Printf("🔒 TLS enabled for status server")
+	}
+	go func() {
+		if err := serve(ln); err != nil && err != http.ErrServerClosed {
+			log.Printf("⚠️  Status server stopped: %v", err)
+		}
+	}()
+	return server, nil
 }
@@ -0,0 +1,615 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/litestream"
+)
+
+// IDs de teste no formato aceito por -id-strategy guid
+const (
+	testClientA = "11111111-1111-1111-1111-111111111111"
+	testClientB = "22222222-2222-2222-2222-222222222222"
+)
+
+// fakeReplicaClients ReplicaClientFactory que registra os clients criados e
+// descarta o que o Litestream envia, sem acessar o S3
+type fakeReplicaClients struct {
+	mutex   sync.Mutex
+	clients []*fakeReplicaClient
+}
+
+func (f *fakeReplicaClients) NewReplicaClient(bucket, path string) litestream.ReplicaClient {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	client := &fakeReplicaClient{bucket: bucket, path: path}
+	f.clients = append(f.clients, client)
+	return client
+}
+
+// paths bucket/path de cada client criado, em ordem
+func (f *fakeReplicaClients) paths() []string {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	paths := make([]string, 0, len(f.clients))
+	for _, client := range f.clients {
+		paths = append(paths, client.bucket+"/"+client.path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// fakeReplicaClient replica vazio: aceita snapshots e WAL sem guardá-los
+type fakeReplicaClient struct {
+	bucket string
+	path   string
+}
+
+func (c *fakeReplicaClient) Type() string { return "fake" }
+
+func (c *fakeReplicaClient) Generations(ctx context.Context) ([]string, error) { return nil, nil }
+
+func (c *fakeReplicaClient) DeleteGeneration(ctx context.Context, generation string) error {
+	return nil
+}
+
+func (c *fakeReplicaClient) Snapshots(ctx context.Context, generation string) (litestream.SnapshotIterator, error) {
+	return litestream.NewSnapshotInfoSliceIterator(nil), nil
+}
+
+func (c *fakeReplicaClient) WriteSnapshot(ctx context.Context, generation string, index int, r io.Reader) (litestream.SnapshotInfo, error) {
+	n, err := io.Copy(io.Discard, r)
+	return litestream.SnapshotInfo{Generation: generation, Index: index, Size: n, CreatedAt: time.Now()}, err
+}
+
+func (c *fakeReplicaClient) DeleteSnapshot(ctx context.Context, generation string, index int) error {
+	return nil
+}
+
+func (c *fakeReplicaClient) SnapshotReader(ctx context.Context, generation string, index int) (io.ReadCloser, error) {
+	return nil, os.ErrNotExist
+}
+
+func (c *fakeReplicaClient) WALSegments(ctx context.Context, generation string) (litestream.WALSegmentIterator, error) {
+	return litestream.NewWALSegmentInfoSliceIterator(nil), nil
+}
+
+func (c *fakeReplicaClient) WriteWALSegment(ctx context.Context, pos litestream.Pos, r io.Reader) (litestream.WALSegmentInfo, error) {
+	n, err := io.Copy(io.Discard, r)
+	return litestream.WALSegmentInfo{Generation: pos.Generation, Index: pos.Index, Offset: pos.Offset, Size: n, CreatedAt: time.Now()}, err
+}
+
+func (c *fakeReplicaClient) DeleteWALSegments(ctx context.Context, a []litestream.Pos) error {
+	return nil
+}
+
+func (c *fakeReplicaClient) WALSegmentReader(ctx context.Context, pos litestream.Pos) (io.ReadCloser, error) {
+	return nil, os.ErrNotExist
+}
+
+// newTestManager gerenciador com as opções padrão das flags e o fake no lugar do S3
+func newTestManager(t *testing.T, watchDir string) (*DatabaseManager, *fakeReplicaClients) {
+	t.Helper()
+
+	tmpl, err := parsePathTemplate(DefaultPathTemplate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dm := NewDatabaseManager(context.Background(), Config{
+		Buckets:                []string{"test-bucket"},
+		WatchDirs:              []string{watchDir},
+		ScanConcurrency:        2,
+		MaxConcurrentRestores:  1,
+		Retention:              litestream.DefaultRetention,
+		RetentionCheckInterval: litestream.DefaultRetentionCheckInterval,
+		SyncInterval:           litestream.DefaultSyncInterval,
+		DBExtensions:           map[string]bool{".db": true},
+		OnIDCollision:          IDCollisionError,
+		PathTemplate:           tmpl,
+		ReplicaType:            ReplicaTypeS3,
+	})
+	fake := &fakeReplicaClients{}
+	dm.replicaClients = fake
+	t.Cleanup(dm.Stop)
+	return dm, fake
+}
+
+// createTestDatabase cria um banco SQLite com uma tabela em path
+func createTestDatabase(t *testing.T, path string) {
+	t.Helper()
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := db.Exec(`CREATE TABLE t (id INTEGER PRIMARY KEY)`); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// registeredClients clientIDs registrados, em ordem
+func registeredClients(dm *DatabaseManager) []string {
+	dm.mutex.RLock()
+	defer dm.mutex.RUnlock()
+	clientIDs := make([]string, 0, len(dm.databases))
+	for clientID := range dm.databases {
+		clientIDs = append(clientIDs, clientID)
+	}
+	sort.Strings(clientIDs)
+	return clientIDs
+}
+
+func TestRegisterAndUnregisterDatabase(t *testing.T) {
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, testClientA+".db")
+	createTestDatabase(t, dbPath)
+
+	dm, fake := newTestManager(t, dir)
+	if err := dm.registerDatabase(dbPath); err != nil {
+		t.Fatalf("registerDatabase: %v", err)
+	}
+
+	if got := registeredClients(dm); len(got) != 1 || got[0] != testClientA {
+		t.Fatalf("registered clients = %v, want [%s]", got, testClientA)
+	}
+	if got, want := fake.paths(), []string{"test-bucket/databases/" + testClientA}; len(got) != 1 || got[0] != want[0] {
+		t.Fatalf("replica clients = %v, want %v", got, want)
+	}
+	if !dm.isPathRegistered(dbPath) {
+		t.Fatalf("path %s not indexed", dbPath)
+	}
+
+	// Registrar de novo o mesmo arquivo não cria um segundo cliente
+	if err := dm.registerDatabase(dbPath); err == nil {
+		t.Fatalf("second registerDatabase succeeded, want error")
+	}
+
+	if err := dm.unregisterDatabase(dbPath); err != nil {
+		t.Fatalf("unregisterDatabase: %v", err)
+	}
+	if got := registeredClients(dm); len(got) != 0 {
+		t.Fatalf("registered clients after unregister = %v, want none", got)
+	}
+	dm.mutex.RLock()
+	_, hasConfig := dm.clients[testClientA]
+	_, hasPath := dm.pathIndex[dbPath]
+	dm.mutex.RUnlock()
+	if hasConfig || hasPath {
+		t.Fatalf("client %s still in clients/pathIndex after unregister", testClientA)
+	}
+}
+
+func TestRegisterDatabaseRejectsNonSQLite(t *testing.T) {
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, testClientA+".db")
+	if err := os.WriteFile(dbPath, []byte("not a database, just text"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	dm, fake := newTestManager(t, dir)
+	if err := dm.registerDatabase(dbPath); err == nil {
+		t.Fatalf("registerDatabase succeeded for a text file")
+	}
+	if got := fake.paths(); len(got) != 0 {
+		t.Fatalf("replica clients = %v, want none", got)
+	}
+}
+
+func TestScanDirectory(t *testing.T) {
+	dir := t.TempDir()
+	createTestDatabase(t, filepath.Join(dir, testClientA+".db"))
+	createTestDatabase(t, filepath.Join(dir, "nested", testClientB+".db"))
+	createTestDatabase(t, filepath.Join(dir, "not-a-guid.db"))
+	if err := os.WriteFile(filepath.Join(dir, testClientA+".txt"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	dm, fake := newTestManager(t, dir)
+	dm.scanDirectory(dir)
+
+	want := []string{testClientA, testClientB}
+	if got := registeredClients(dm); len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Fatalf("registered clients = %v, want %v", got, want)
+	}
+	if got := fake.paths(); len(got) != 2 {
+		t.Fatalf("replica clients = %v, want one per client", got)
+	}
+
+	// Uma segunda varredura não registra nada de novo
+	dm.scanDirectory(dir)
+	if got := fake.paths(); len(got) != 2 {
+		t.Fatalf("replica clients after rescan = %v, want 2", got)
+	}
+}
+
+func TestExtractClientID(t *testing.T) {
+	const mixed = "AbCdEf01-2345-6789-ABCD-ef0123456789"
+	const lower = "abcdef01-2345-6789-abcd-ef0123456789"
+	tests := []struct {
+		name string
+		path string
+		want string
+	}{
+		{"valid", "/data/" + testClientA + ".db", testClientA},
+		{"valid lowercase hex", "/data/" + lower + ".db", lower},
+		{"uppercase", "/data/" + strings.ToUpper(lower) + ".db", lower},
+		{"mixed case", "/data/" + mixed + ".db", lower},
+		{"braced", "/data/{" + testClientA + "}.db", testClientA},
+		{"braced uppercase", "/data/{" + strings.ToUpper(lower) + "}.db", lower},
+		{"other extension", "/data/" + testClientA + ".sqlite", testClientA},
+		{"not a guid", "/data/tenant-acme.db", ""},
+		{"too short", "/data/11111111-1111-1111-1111-11111111111.db", ""},
+		{"too long", "/data/11111111-1111-1111-1111-1111111111111.db", ""},
+		{"underscores", "/data/11111111_1111_1111_1111_111111111111.db", ""},
+		{"non-hex digit", "/data/1111111g-1111-1111-1111-111111111111.db", ""},
+		{"unbalanced brace", "/data/{" + testClientA + ".db", ""},
+		{"empty name", "/data/.db", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := extractClientID(tt.path); got != tt.want {
+				t.Errorf("extractClientID(%q) = %q, want %q", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseBucketMap(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    map[string]string
+		wantErr string
+	}{
+		{"empty", "", map[string]string{}, ""},
+		{"single", "/data/a=bucket-a", map[string]string{"/data/a": "bucket-a"}, ""},
+		{"several with spaces", " /data/a = bucket-a , /data/b=bucket-b,", map[string]string{"/data/a": "bucket-a", "/data/b": "bucket-b"}, ""},
+		{"last equals wins", "/data/x=y=bucket", map[string]string{"/data/x=y": "bucket"}, ""},
+		{"missing equals", "/data/a", nil, "expected dir=bucket"},
+		{"empty dir", "=bucket-a", nil, "expected dir=bucket"},
+		{"empty bucket", "/data/a=", nil, "expected dir=bucket"},
+		{"duplicate dir", "/data/a=bucket-a,/data/a=bucket-b", nil, "mapped more than once"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseBucketMap(tt.value)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("parseBucketMap(%q) error = %v, want %q", tt.value, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseBucketMap(%q): %v", tt.value, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseBucketMap(%q) = %v, want %v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBucketsFor(t *testing.T) {
+	dm := &DatabaseManager{
+		config: Config{BucketMap: map[string]string{
+			"/data":        "bucket-data",
+			"/data/vip":    "bucket-vip",
+			"/data/vip2/":  "bucket-vip2",
+			"/other/named": "bucket-named",
+		}},
+		buckets: []string{"bucket-1", "bucket-2"},
+	}
+	tests := []struct {
+		name string
+		path string
+		want []string
+	}{
+		{"mapped dir", "/data/" + testClientA + ".db", []string{"bucket-data"}},
+		{"innermost mapped dir", "/data/vip/" + testClientA + ".db", []string{"bucket-vip"}},
+		{"subdirectory of mapped dir", "/data/vip/nested/" + testClientA + ".db", []string{"bucket-vip"}},
+		{"trailing separator in map", "/data/vip2/" + testClientA + ".db", []string{"bucket-vip2"}},
+		{"sibling with same prefix", "/data/vipers/" + testClientA + ".db", []string{"bucket-data"}},
+		{"unmapped dir", "/srv/" + testClientA + ".db", []string{"bucket-1", "bucket-2"}},
+		{"name prefix is not a dir", "/other/named.db", []string{"bucket-1", "bucket-2"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := dm.bucketsFor(tt.path); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("bucketsFor(%q) = %v, want %v", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParsePathTemplate(t *testing.T) {
+	data := PathTemplateData{ClientID: testClientA, Env: "prod", Year: "2024", Month: "05", Day: "09"}
+	tests := []struct {
+		name    string
+		text    string
+		want    string
+		wantErr bool
+	}{
+		{"default", DefaultPathTemplate, "databases/" + testClientA, false},
+		{"all fields", "{{.Env}}/{{.Year}}/{{.Month}}/{{.Day}}/{{.ClientID}}", "prod/2024/05/09/" + testClientA, false},
+		{"slashes trimmed", "/tenants/{{.ClientID}}/", "tenants/" + testClientA, false},
+		{"static", "backups", "backups", false},
+		{"bad syntax", "databases/{{.ClientID", "", true},
+		{"unknown field", "databases/{{.Tenant}}", "", true},
+		{"empty path", "/", "", true},
+		{"empty template", "", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl, err := parsePathTemplate(tt.text)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("parsePathTemplate(%q) succeeded, want error", tt.text)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parsePathTemplate(%q): %v", tt.text, err)
+			}
+			got, err := renderPath(tmpl, data)
+			if err != nil {
+				t.Fatalf("renderPath(%q): %v", tt.text, err)
+			}
+			if got != tt.want {
+				t.Errorf("renderPath(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseClientPage(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		want    clientPage
+		wantErr bool
+	}{
+		{"no params", "", clientPage{}, false},
+		{"all params", "status=paused&limit=10&offset=20", clientPage{Status: "paused", Limit: 10, Offset: 20}, false},
+		{"dry-run", "status=dry-run", clientPage{Status: "dry-run"}, false},
+		{"zero limit", "limit=0", clientPage{}, false},
+		{"failed is dashboard only", "status=failed", clientPage{}, true},
+		{"unknown status", "status=deleted", clientPage{}, true},
+		{"negative limit", "limit=-1", clientPage{}, true},
+		{"non-numeric offset", "offset=abc", clientPage{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, err := url.ParseQuery(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := parseClientPage(query)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("parseClientPage(%q) = %+v, want error", tt.query, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseClientPage(%q): %v", tt.query, err)
+			}
+			if got != tt.want {
+				t.Errorf("parseClientPage(%q) = %+v, want %+v", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClientPageApply(t *testing.T) {
+	clients := make([]map[string]interface{}, 5)
+	for i := range clients {
+		clients[i] = map[string]interface{}{"index": i}
+	}
+	tests := []struct {
+		name string
+		page clientPage
+		want []int
+	}{
+		{"everything", clientPage{}, []int{0, 1, 2, 3, 4}},
+		{"limit", clientPage{Limit: 2}, []int{0, 1}},
+		{"offset", clientPage{Offset: 3}, []int{3, 4}},
+		{"limit and offset", clientPage{Limit: 2, Offset: 1}, []int{1, 2}},
+		{"limit past end", clientPage{Limit: 10, Offset: 4}, []int{4}},
+		{"offset at end", clientPage{Offset: 5}, []int{}},
+		{"offset past end", clientPage{Offset: 50}, []int{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := []int{}
+			for _, client := range tt.page.apply(clients) {
+				got = append(got, client["index"].(int))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("%+v.apply() = %v, want %v", tt.page, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAllowCORS(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	tests := []struct {
+		name      string
+		origins   []string
+		method    string
+		path      string
+		origin    string
+		preflight bool
+		wantCode  int
+		wantAllow string
+	}{
+		{"disabled", nil, http.MethodGet, "/api/status", "https://app.example.com", false, http.StatusOK, ""},
+		{"allowed origin", []string{"https://app.example.com/"}, http.MethodGet, "/api/status", "https://app.example.com", false, http.StatusOK, "https://app.example.com"},
+		{"wildcard", []string{"*"}, http.MethodGet, "/api/status", "https://other.example.com", false, http.StatusOK, "https://other.example.com"},
+		{"other origin", []string{"https://app.example.com"}, http.MethodGet, "/api/status", "https://evil.example.com", false, http.StatusOK, ""},
+		{"no origin header", []string{"*"}, http.MethodGet, "/api/status", "", false, http.StatusOK, ""},
+		{"dashboard", []string{"*"}, http.MethodGet, "/", "https://app.example.com", false, http.StatusOK, ""},
+		{"preflight", []string{"https://app.example.com"}, http.MethodOptions, "/api/clients/x/pause", "https://app.example.com", true, http.StatusNoContent, "https://app.example.com"},
+		{"plain options", []string{"https://app.example.com"}, http.MethodOptions, "/api/status", "https://app.example.com", false, http.StatusOK, "https://app.example.com"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+			if tt.preflight {
+				req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+			}
+			rec := httptest.NewRecorder()
+			allowCORS(next, tt.origins).ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantCode {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantCode)
+			}
+			if got := rec.Header().Get("Access-Control-Allow-Origin"); got != tt.wantAllow {
+				t.Errorf("Access-Control-Allow-Origin = %q, want %q", got, tt.wantAllow)
+			}
+		})
+	}
+}
+
+func TestRequireToken(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	tests := []struct {
+		name             string
+		token            string
+		protectDashboard bool
+		path             string
+		auth             string
+		wantCode         int
+	}{
+		{"disabled", "", true, "/api/status", "", http.StatusOK},
+		{"api without token", "secret", false, "/api/status", "", http.StatusUnauthorized},
+		{"api with token", "secret", false, "/api/status", "Bearer secret", http.StatusOK},
+		{"api with wrong token", "secret", false, "/api/status", "Bearer other", http.StatusUnauthorized},
+		{"api without scheme", "secret", false, "/api/status", "secret", http.StatusUnauthorized},
+		{"dashboard open", "secret", false, "/", "", http.StatusOK},
+		{"dashboard protected", "secret", true, "/", "", http.StatusUnauthorized},
+		{"dashboard with token", "secret", true, "/", "Bearer secret", http.StatusOK},
+		{"metrics open", "secret", true, "/metrics", "", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.auth != "" {
+				req.Header.Set("Authorization", tt.auth)
+			}
+			rec := httptest.NewRecorder()
+			requireToken(next, tt.token, tt.protectDashboard).ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantCode {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantCode)
+			}
+			if challenge := rec.Header().Get("WWW-Authenticate"); (tt.wantCode == http.StatusUnauthorized) != (challenge != "") {
+				t.Errorf("WWW-Authenticate = %q with status %d", challenge, rec.Code)
+			}
+		})
+	}
+}
+
+func TestIsEmptyDatabase(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name   string
+		file   string
+		tables bool
+		want   bool
+	}{
+		{"empty", "empty.db", false, true},
+		{"with table", "full.db", true, false},
+		// ? e # no nome não podem virar parâmetros ou fragmento do DSN
+		{"empty with uri chars", "empty?mode=rw#x.db", false, true},
+		{"with table and uri chars", "full?mode=rw#x.db", true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, tt.file)
+			db, err := sql.Open("sqlite3", sqliteURI(path, nil))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer db.Close()
+			stmt := `PRAGMA user_version = 1`
+			if tt.tables {
+				stmt = `CREATE TABLE t (id INTEGER PRIMARY KEY)`
+			}
+			if _, err := db.Exec(stmt); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := isEmptyDatabase(path, time.Second)
+			if err != nil {
+				t.Fatalf("isEmptyDatabase(%q): %v", path, err)
+			}
+			if got != tt.want {
+				t.Errorf("isEmptyDatabase(%q) = %v, want %v", path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScanDirectorySkipsLitestreamMetaDir(t *testing.T) {
+	dir := t.TempDir()
+	createTestDatabase(t, filepath.Join(dir, testClientA+".db"))
+	// Bancos com nome de GUID dentro dos diretórios internos do Litestream
+	// (na raiz e em um subdiretório) nunca viram clientes
+	createTestDatabase(t, filepath.Join(dir, "."+testClientA+".db-litestream", "generations", "0123456789abcdef", testClientB+".db"))
+	createTestDatabase(t, filepath.Join(dir, "nested", ".x.db-litestream", testClientB+".db"))
+
+	dm, fake := newTestManager(t, dir)
+	dm.scanDirectory(dir)
+
+	if got := registeredClients(dm); len(got) != 1 || got[0] != testClientA {
+		t.Fatalf("registered clients = %v, want [%s]", got, testClientA)
+	}
+	if got, want := fake.paths(), "test-bucket/databases/"+testClientA; len(got) != 1 || got[0] != want {
+		t.Fatalf("replica clients = %v, want [%s]", got, want)
+	}
+}
+
+func TestIsIgnoredFile(t *testing.T) {
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"/data/" + testClientA + ".db", false},
+		{"/data/nested/" + testClientA + ".db", false},
+		{"/data/.x.db-litestream/generations/0123456789abcdef/" + testClientA + ".db", true},
+		{"/data/" + testClientA + ".db" + restoreTempSuffix, true},
+		{"/data/" + testClientA + ".db" + restoreTempSuffix + ".tmp", true},
+		{"/data/" + testClientA + ".db-wal", true},
+		{"/data/" + accessTestPrefix + "-123", true},
+	}
+	for _, tt := range tests {
+		if got := isIgnoredFile(tt.path); got != tt.want {
+			t.Errorf("isIgnoredFile(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}